@@ -0,0 +1,229 @@
+package main
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+	"strings"
+
+	"github.com/NVIDIA/go-nvml/pkg/nvml"
+)
+
+// ---------- GPU backend (NVML or simulated) ----------
+
+// Device is the subset of per-GPU operations the daemon and CLI use.
+// Results are reported as nvml.Return so every backend shares the same
+// SUCCESS / ERROR_NOT_SUPPORTED handling and the same log text.
+type Device interface {
+	UUID() (string, nvml.Return)
+	PCIBusID() (string, nvml.Return)
+	Name() (string, nvml.Return)
+	Temperature() (int, nvml.Return)
+	MemoryTemperature() (int, nvml.Return)  // FI_DEV_MEMORY_TEMP field value
+	HotspotTemperature() (int, nvml.Return) // junction hotspot, where the backend exposes it
+	TemperatureThreshold(kind nvml.TemperatureThresholds) (int, nvml.Return)
+	PowerUsage() (int, nvml.Return)                  // DeviceGetPowerUsage, milliwatts
+	Utilization() (gpu, memory int, ret nvml.Return) // DeviceGetUtilizationRates, percent
+	NumFans() (int, nvml.Return)
+	FanSpeed(fanIdx int) (int, nvml.Return) // DeviceGetFanSpeed_v2
+	FanSpeedLegacy() (int, nvml.Return)     // DeviceGetFanSpeed (fan 0 only)
+	FanSpeedRPM(fanIdx int) (int, nvml.Return)
+	MinMaxFanSpeed() (minSpeed, maxSpeed int, ret nvml.Return) // DeviceGetMinMaxFanSpeed
+	CoolerInfo(fanIdx int) (coolerInfo, nvml.Return)           // DeviceGetCoolerInfo
+	SetFanSpeed(fanIdx, speed int) nvml.Return
+	SetFanControlPolicy(fanIdx int, policy nvml.FanControlPolicy) nvml.Return
+	SetDefaultFanSpeed(fanIdx int) nvml.Return // DeviceSetDefaultFanSpeed_v2
+}
+
+// Backend enumerates devices. Init/Shutdown bracket every use.
+type Backend interface {
+	Name() string
+	Init() nvml.Return
+	Shutdown() nvml.Return
+	DeviceCount() (int, nvml.Return)
+	DeviceByIndex(idx int) (Device, nvml.Return)
+}
+
+// ---------- NVML backend ----------
+
+type nvmlBackend struct{}
+
+type nvmlDevice struct {
+	dev nvml.Device
+}
+
+func (nvmlBackend) Name() string          { return "NVML" }
+func (nvmlBackend) Init() nvml.Return     { return nvml.Init() }
+func (nvmlBackend) Shutdown() nvml.Return { return nvml.Shutdown() }
+
+func (nvmlBackend) DeviceCount() (int, nvml.Return) {
+	return nvml.DeviceGetCount()
+}
+
+func (nvmlBackend) DeviceByIndex(idx int) (Device, nvml.Return) {
+	dev, ret := nvml.DeviceGetHandleByIndex(idx)
+	if ret != nvml.SUCCESS {
+		return nil, ret
+	}
+	return nvmlDevice{dev: dev}, nvml.SUCCESS
+}
+
+func (d nvmlDevice) UUID() (string, nvml.Return) {
+	return nvml.DeviceGetUUID(d.dev)
+}
+
+func (d nvmlDevice) PCIBusID() (string, nvml.Return) {
+	info, ret := nvml.DeviceGetPciInfo(d.dev)
+	if ret != nvml.SUCCESS {
+		return "", ret
+	}
+	var b strings.Builder
+	for _, c := range info.BusId {
+		if c == 0 {
+			break
+		}
+		b.WriteByte(byte(c))
+	}
+	return b.String(), nvml.SUCCESS
+}
+
+func (d nvmlDevice) Name() (string, nvml.Return) {
+	return nvml.DeviceGetName(d.dev)
+}
+
+func (d nvmlDevice) Temperature() (int, nvml.Return) {
+	temp, ret := nvml.DeviceGetTemperature(d.dev, nvml.TEMPERATURE_GPU)
+	return int(temp), ret
+}
+
+func (d nvmlDevice) MemoryTemperature() (int, nvml.Return) {
+	values := []nvml.FieldValue{{FieldId: nvml.FI_DEV_MEMORY_TEMP}}
+	if ret := nvml.DeviceGetFieldValues(d.dev, values); ret != nvml.SUCCESS {
+		return 0, ret
+	}
+	if ret := nvml.Return(values[0].NvmlReturn); ret != nvml.SUCCESS {
+		return 0, ret
+	}
+	temp, ok := fieldValueInt(values[0])
+	// Boards without a memory sensor commonly report 0 instead of an error.
+	if !ok || temp <= 0 {
+		return 0, nvml.ERROR_NOT_SUPPORTED
+	}
+	return temp, nvml.SUCCESS
+}
+
+// HotspotTemperature is not available: NVML (as bound by go-nvml v0.12) has
+// no hotspot sensor, only TEMPERATURE_GPU and the memory field value.
+func (d nvmlDevice) HotspotTemperature() (int, nvml.Return) {
+	return 0, nvml.ERROR_NOT_SUPPORTED
+}
+
+// fieldValueInt decodes a field value's union. NVML writes it in host byte
+// order; every platform the driver supports is little-endian.
+func fieldValueInt(fv nvml.FieldValue) (int, bool) {
+	v := fv.Value[:]
+	switch nvml.ValueType(fv.ValueType) {
+	case nvml.VALUE_TYPE_DOUBLE:
+		return int(math.Round(math.Float64frombits(binary.LittleEndian.Uint64(v)))), true
+	case nvml.VALUE_TYPE_UNSIGNED_INT:
+		return int(binary.LittleEndian.Uint32(v)), true
+	case nvml.VALUE_TYPE_SIGNED_INT:
+		return int(int32(binary.LittleEndian.Uint32(v))), true
+	case nvml.VALUE_TYPE_UNSIGNED_LONG, nvml.VALUE_TYPE_UNSIGNED_LONG_LONG:
+		return int(binary.LittleEndian.Uint64(v)), true
+	case nvml.VALUE_TYPE_SIGNED_LONG_LONG:
+		return int(int64(binary.LittleEndian.Uint64(v))), true
+	}
+	return 0, false
+}
+
+func (d nvmlDevice) TemperatureThreshold(kind nvml.TemperatureThresholds) (int, nvml.Return) {
+	temp, ret := nvml.DeviceGetTemperatureThreshold(d.dev, kind)
+	return int(temp), ret
+}
+
+func (d nvmlDevice) PowerUsage() (int, nvml.Return) {
+	mw, ret := nvml.DeviceGetPowerUsage(d.dev)
+	return int(mw), ret
+}
+
+func (d nvmlDevice) Utilization() (int, int, nvml.Return) {
+	u, ret := nvml.DeviceGetUtilizationRates(d.dev)
+	return int(u.Gpu), int(u.Memory), ret
+}
+
+func (d nvmlDevice) NumFans() (int, nvml.Return) {
+	return nvml.DeviceGetNumFans(d.dev)
+}
+
+func (d nvmlDevice) FanSpeed(fanIdx int) (int, nvml.Return) {
+	speed, ret := nvml.DeviceGetFanSpeed_v2(d.dev, fanIdx)
+	return int(speed), ret
+}
+
+func (d nvmlDevice) FanSpeedLegacy() (int, nvml.Return) {
+	speed, ret := nvml.DeviceGetFanSpeed(d.dev)
+	return int(speed), ret
+}
+
+// FanSpeedRPM is not implemented for NVML yet: the go-nvml release in go.mod
+// (v0.12.4) has no nvmlDeviceGetFanSpeedRPM binding, so calibrate refuses to run.
+func (d nvmlDevice) FanSpeedRPM(fanIdx int) (int, nvml.Return) {
+	return 0, nvml.ERROR_FUNCTION_NOT_FOUND
+}
+
+func (d nvmlDevice) MinMaxFanSpeed() (int, int, nvml.Return) {
+	return nvml.DeviceGetMinMaxFanSpeed(d.dev)
+}
+
+// CoolerInfo is not implemented for NVML yet: the go-nvml release in go.mod
+// (v0.12.4) has no nvmlDeviceGetCoolerInfo binding. Reading real targets needs
+// a go.mod bump to a release that binds it; until then every fan follows the
+// device input unless a fans override sets a sensor.
+func (d nvmlDevice) CoolerInfo(fanIdx int) (coolerInfo, nvml.Return) {
+	return coolerInfo{}, nvml.ERROR_FUNCTION_NOT_FOUND
+}
+
+func (d nvmlDevice) SetFanSpeed(fanIdx, speed int) nvml.Return {
+	return nvml.DeviceSetFanSpeed_v2(d.dev, fanIdx, speed)
+}
+
+func (d nvmlDevice) SetFanControlPolicy(fanIdx int, policy nvml.FanControlPolicy) nvml.Return {
+	return nvml.DeviceSetFanControlPolicy(d.dev, fanIdx, policy)
+}
+
+func (d nvmlDevice) SetDefaultFanSpeed(fanIdx int) nvml.Return {
+	return nvml.DeviceSetDefaultFanSpeed_v2(d.dev, fanIdx)
+}
+
+// ---------- Backend selection ----------
+
+type backendOptions struct {
+	name       string
+	simGPUs    int
+	simFans    int
+	simTemps   string
+	simCoolers string
+}
+
+func newBackend(opts backendOptions) (Backend, error) {
+	switch opts.name {
+	case "", "nvml":
+		return nvmlBackend{}, nil
+	case "sim":
+		temps, err := parseIntList(opts.simTemps)
+		if err != nil {
+			return nil, fmt.Errorf("invalid -sim-temps: %w", err)
+		}
+		b, err := newSimBackend(opts.simGPUs, opts.simFans, temps)
+		if err != nil {
+			return nil, err
+		}
+		if err := b.setCoolerTargets(opts.simCoolers); err != nil {
+			return nil, fmt.Errorf("invalid -sim-coolers: %w", err)
+		}
+		return b, nil
+	default:
+		return nil, fmt.Errorf("unknown backend %q (expected nvml|sim)", opts.name)
+	}
+}
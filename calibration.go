@@ -0,0 +1,459 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"os"
+	"os/signal"
+	"strconv"
+	"syscall"
+	"text/tabwriter"
+	"time"
+
+	"github.com/NVIDIA/go-nvml/pkg/nvml"
+)
+
+// ---------- calibrate: measure each fan's usable speed range ----------
+
+// CalibrationFile is what `calibrate` writes and `calibration_file` reads.
+type CalibrationFile struct {
+	GPUs []GPUCalibration `json:"gpus"`
+}
+
+type GPUCalibration struct {
+	GPU  GPUSelector      `json:"gpu"` // uuid:... when the GPU reports one
+	Name string           `json:"name,omitempty"`
+	Fans []FanCalibration `json:"fans"`
+}
+
+// FanCalibration is the measured range of one fan. The daemon maps its
+// 1..100% output onto MinSpeed..MaxSpeed; 0% is passed through unchanged.
+type FanCalibration struct {
+	Fan           int                `json:"fan"`
+	MinSpeed      int                `json:"min_speed"`      // lowest command at which the fan spins
+	MaxSpeed      int                `json:"max_speed"`      // command above which it stops getting faster
+	SettleSeconds float64            `json:"settle_seconds"` // slowest settle observed during the sweep
+	Source        string             `json:"source"`         // "rpm"; files from older versions may say "reported"
+	Points        []CalibrationPoint `json:"points,omitempty"`
+}
+
+type CalibrationPoint struct {
+	Speed         int     `json:"speed"`
+	Value         int     `json:"value"` // RPM
+	SettleSeconds float64 `json:"settle_seconds"`
+	Rejected      bool    `json:"rejected,omitempty"`
+}
+
+// validate checks the ranges the daemon relies on.
+func (cf *CalibrationFile) validate() error {
+	for _, g := range cf.GPUs {
+		if g.GPU == "" {
+			return fmt.Errorf("calibration entry without gpu")
+		}
+		for _, f := range g.Fans {
+			if f.Fan < 0 || f.MinSpeed < 0 || f.MaxSpeed > 100 || f.MinSpeed >= f.MaxSpeed {
+				return fmt.Errorf("gpu %q fan %d: need 0 <= min_speed < max_speed <= 100 (got %d..%d)",
+					g.GPU, f.Fan, f.MinSpeed, f.MaxSpeed)
+			}
+		}
+	}
+	return nil
+}
+
+func loadCalibrationFile(path string) (CalibrationFile, error) {
+	var cf CalibrationFile
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return cf, err
+	}
+	if err := json.Unmarshal(data, &cf); err != nil {
+		return cf, fmt.Errorf("%s: %w", path, err)
+	}
+	if err := cf.validate(); err != nil {
+		return cf, fmt.Errorf("%s: %w", path, err)
+	}
+	return cf, nil
+}
+
+// merge replaces the fans in cal for its GPU and keeps everything else.
+func (cf *CalibrationFile) merge(cal GPUCalibration) {
+	for gi := range cf.GPUs {
+		g := &cf.GPUs[gi]
+		if g.GPU != cal.GPU {
+			continue
+		}
+		g.Name = cal.Name
+		for _, f := range cal.Fans {
+			replaced := false
+			for fi := range g.Fans {
+				if g.Fans[fi].Fan == f.Fan {
+					g.Fans[fi], replaced = f, true
+				}
+			}
+			if !replaced {
+				g.Fans = append(g.Fans, f)
+			}
+		}
+		return
+	}
+	cf.GPUs = append(cf.GPUs, cal)
+}
+
+// calibratedBackend remaps fan commands of calibrated GPUs onto their usable range.
+type calibratedBackend struct {
+	Backend
+	fans map[int]map[int]FanCalibration // GPU index -> fan index -> range
+}
+
+type calibratedDevice struct {
+	Device
+	fans map[int]FanCalibration
+}
+
+// applyCalibration loads path and wraps backend so every calibrated fan's
+// commands are remapped. Entries for GPUs that are not present are skipped.
+func applyCalibration(backend Backend, path string) (Backend, error) {
+	cf, err := loadCalibrationFile(path)
+	if err != nil {
+		return backend, err
+	}
+	cb := &calibratedBackend{Backend: backend, fans: map[int]map[int]FanCalibration{}}
+	for _, g := range cf.GPUs {
+		idx, err := resolveGPU(backend, g.GPU)
+		if err != nil {
+			log.Printf("WARN: Calibration for %s skipped: %v", g.GPU, err)
+			continue
+		}
+		if cb.fans[idx] == nil {
+			cb.fans[idx] = map[int]FanCalibration{}
+		}
+		for _, f := range g.Fans {
+			cb.fans[idx][f.Fan] = f
+			log.Printf("INFO: GPU %d Fan %d calibrated: 1..100%% maps to %d..%d%% (%s, settles in %.1fs)",
+				idx, f.Fan, f.MinSpeed, f.MaxSpeed, f.Source, f.SettleSeconds)
+		}
+	}
+	return cb, nil
+}
+
+func (cb *calibratedBackend) DeviceByIndex(idx int) (Device, nvml.Return) {
+	dev, ret := cb.Backend.DeviceByIndex(idx)
+	if ret != nvml.SUCCESS || cb.fans[idx] == nil {
+		return dev, ret
+	}
+	return calibratedDevice{Device: dev, fans: cb.fans[idx]}, ret
+}
+
+func (d calibratedDevice) SetFanSpeed(fanIdx, speed int) nvml.Return {
+	if f, ok := d.fans[fanIdx]; ok && speed > 0 {
+		speed = f.MinSpeed + int(math.Round(float64(speed*(f.MaxSpeed-f.MinSpeed))/100))
+	}
+	return d.Device.SetFanSpeed(fanIdx, speed)
+}
+
+func (d calibratedDevice) FanSpeed(fanIdx int) (int, nvml.Return) {
+	speed, ret := d.Device.FanSpeed(fanIdx)
+	return d.unmap(fanIdx, speed), ret
+}
+
+func (d calibratedDevice) FanSpeedLegacy() (int, nvml.Return) {
+	speed, ret := d.Device.FanSpeedLegacy()
+	return d.unmap(0, speed), ret
+}
+
+// calibratedFan reports whether dev remaps fanIdx onto a calibrated range.
+func calibratedFan(dev Device, fanIdx int) bool {
+	cd, ok := dev.(calibratedDevice)
+	if !ok {
+		return false
+	}
+	_, ok = cd.fans[fanIdx]
+	return ok
+}
+
+// unmap converts a reported speed back to the daemon's 0..100% scale.
+func (d calibratedDevice) unmap(fanIdx, speed int) int {
+	f, ok := d.fans[fanIdx]
+	if !ok || speed <= 0 {
+		return speed
+	}
+	return clampInt(int(math.Round(float64((speed-f.MinSpeed)*100)/float64(f.MaxSpeed-f.MinSpeed))), 0, 100)
+}
+
+type calibrateOptions struct {
+	gpu           GPUSelector
+	fans          string
+	out           string
+	step          int
+	poll          time.Duration
+	settleTimeout time.Duration
+	verbose       bool
+}
+
+const (
+	calibrateStablePolls    = 3    // consecutive readings that must agree
+	calibrateSpinning       = 0.10 // share of the top reading that counts as spinning
+	calibrateSaturation     = 0.97 // share of the top reading that counts as saturated
+	calibrateToleranceRPM   = 10   // readings this close (in RPM) agree...
+	calibrateToleranceShare = 0.01 // ...or within this share of the reading
+)
+
+// requireTachometer checks that fanIdx reports RPM. The reported speed only
+// echoes the command, so without a tachometer a sweep can't see a stall,
+// saturation or settling, and would just reproduce the driver's min/max.
+func requireTachometer(dev Device, fanIdx int) error {
+	_, ret := dev.FanSpeedRPM(fanIdx)
+	switch ret {
+	case nvml.SUCCESS:
+		return nil
+	case nvml.ERROR_FUNCTION_NOT_FOUND:
+		return fmt.Errorf("Fan %d: this build cannot read fan RPM from NVML (go-nvml v0.12.4 has no nvmlDeviceGetFanSpeedRPM); calibration needs a tachometer and currently only works with -backend sim", fanIdx)
+	default:
+		return fmt.Errorf("Fan %d has no RPM reading (%v); calibration needs a tachometer", fanIdx, nvml.ErrorString(ret))
+	}
+}
+
+func cmdCalibrate(backend Backend, opts calibrateOptions) int {
+	configureCLILogging(opts.verbose)
+
+	if opts.step < 1 || opts.step > 100 {
+		fmt.Fprintf(os.Stderr, "calibrate: -step must be 1..100 (got %d)\n", opts.step)
+		return 2
+	}
+	if opts.poll <= 0 || opts.settleTimeout < opts.poll {
+		fmt.Fprintln(os.Stderr, "calibrate: -poll must be > 0 and -settle-timeout at least one poll")
+		return 2
+	}
+
+	cleanup, err := initializeBackend(backend)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	defer cleanup()
+
+	gpuIdx, err := resolveGPU(backend, opts.gpu)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	dev, err := deviceHandleByIndex(backend, gpuIdx)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	numFans, ret := dev.NumFans()
+	if ret != nvml.SUCCESS {
+		fmt.Fprintf(os.Stderr, "unable to get fan count for device %d: %v\n", gpuIdx, nvml.ErrorString(ret))
+		return 1
+	}
+
+	var fans []int
+	if opts.fans == "" || opts.fans == "all" {
+		for f := 0; f < numFans; f++ {
+			fans = append(fans, f)
+		}
+	} else if fans, err = parseFanList(opts.fans); err != nil {
+		fmt.Fprintln(os.Stderr, "calibrate:", err)
+		return 2
+	}
+	for _, fanIdx := range fans {
+		if fanIdx >= numFans {
+			fmt.Fprintf(os.Stderr, "invalid fan index %d for GPU %d (device reports %d fan(s))\n", fanIdx, gpuIdx, numFans)
+			return 1
+		}
+		if err := requireTachometer(dev, fanIdx); err != nil {
+			fmt.Fprintf(os.Stderr, "calibrate: GPU %d %v\n", gpuIdx, err)
+			return 1
+		}
+	}
+
+	lo, hi, ret := dev.MinMaxFanSpeed()
+	if ret != nvml.SUCCESS {
+		lo, hi = 0, 100
+		fmt.Fprintf(os.Stderr, "min/max fan speed unavailable (%v); sweeping 0..100%%\n", nvml.ErrorString(ret))
+	}
+
+	id := identifyDevice(dev)
+	cal := GPUCalibration{GPU: GPUSelector(strconv.Itoa(gpuIdx)), Name: id.name}
+	if id.uuid != "" {
+		cal.GPU = GPUSelector("uuid:" + id.uuid)
+	}
+
+	// Whatever happens below, the fans go back to the driver.
+	label := fmt.Sprintf("GPU %d", gpuIdx)
+	defer func() {
+		for _, fanIdx := range fans {
+			if how, ok := handToDriver(dev, label, fanIdx); ok {
+				fmt.Printf("%s Fan %d restored to %s.\n", label, fanIdx, how)
+			}
+		}
+	}()
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGTERM, syscall.SIGINT)
+	defer stop()
+
+	fmt.Printf("Calibrating %s (%s), fans %s, %d..%d%% in steps of %d%%\n",
+		label, id, formatFanList(fans), lo, hi, opts.step)
+	for _, fanIdx := range fans {
+		fc, err := calibrateFan(ctx, dev, fanIdx, lo, hi, opts)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%s Fan %d: %v\n", label, fanIdx, err)
+			return 1
+		}
+		printFanCalibration(label, fc)
+		cal.Fans = append(cal.Fans, fc)
+	}
+
+	var cf CalibrationFile
+	if existing, err := loadCalibrationFile(opts.out); err == nil {
+		cf = existing
+	} else if !errors.Is(err, os.ErrNotExist) {
+		fmt.Fprintf(os.Stderr, "calibrate: not merging into existing file: %v\n", err)
+	}
+	cf.merge(cal)
+	data, err := json.MarshalIndent(cf, "", "  ")
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	if err := os.WriteFile(opts.out, append(data, '\n'), 0o644); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	fmt.Printf("Wrote %s (set \"calibration_file\" in config.json to use it).\n", opts.out)
+	return 0
+}
+
+// calibrateFan sweeps one fan upward from lo to hi and derives its usable range
+// from its RPM.
+func calibrateFan(ctx context.Context, dev Device, fanIdx, lo, hi int, opts calibrateOptions) (FanCalibration, error) {
+	fc := FanCalibration{Fan: fanIdx, Source: "rpm"}
+	if err := requireTachometer(dev, fanIdx); err != nil {
+		return fc, err
+	}
+	read := func() (int, nvml.Return) {
+		return dev.FanSpeedRPM(fanIdx)
+	}
+
+	ret := dev.SetFanControlPolicy(fanIdx, nvml.FAN_POLICY_MANUAL)
+	if ret != nvml.SUCCESS && ret != nvml.ERROR_NOT_SUPPORTED {
+		return fc, fmt.Errorf("unable to set manual fan policy: %v", nvml.ErrorString(ret))
+	}
+	// Start from rest (or the slowest the driver allows), not from the AUTO speed.
+	if ret := dev.SetFanSpeed(fanIdx, lo); ret == nvml.SUCCESS {
+		if _, _, err := waitSettled(ctx, read, opts); err != nil {
+			return fc, err
+		}
+	}
+
+	for speed := lo; ; speed += opts.step {
+		if speed > hi {
+			speed = hi
+		}
+		pt := CalibrationPoint{Speed: speed}
+		if ret := dev.SetFanSpeed(fanIdx, speed); ret != nvml.SUCCESS {
+			log.Printf("WARN: Fan %d rejected %d%%: %v", fanIdx, speed, nvml.ErrorString(ret))
+			pt.Rejected = true
+		} else {
+			value, settle, err := waitSettled(ctx, read, opts)
+			if err != nil {
+				return fc, err
+			}
+			pt.Value, pt.SettleSeconds = value, settle
+			log.Printf("INFO: Fan %d at %d%%: %d (%s) after %.2fs", fanIdx, speed, value, fc.Source, settle)
+		}
+		fc.Points = append(fc.Points, pt)
+		if speed == hi {
+			break
+		}
+	}
+
+	top := 0
+	for _, pt := range fc.Points {
+		if !pt.Rejected && pt.Value > top {
+			top = pt.Value
+		}
+		if pt.SettleSeconds > fc.SettleSeconds {
+			fc.SettleSeconds = pt.SettleSeconds
+		}
+	}
+	if top == 0 {
+		return fc, fmt.Errorf("fan never spun between %d%% and %d%%", lo, hi)
+	}
+	fc.MinSpeed, fc.MaxSpeed = -1, -1
+	for _, pt := range fc.Points {
+		if pt.Rejected {
+			continue
+		}
+		if fc.MinSpeed < 0 && float64(pt.Value) >= calibrateSpinning*float64(top) {
+			fc.MinSpeed = pt.Speed
+		}
+		if fc.MaxSpeed < 0 && float64(pt.Value) >= calibrateSaturation*float64(top) {
+			fc.MaxSpeed = pt.Speed
+		}
+	}
+	if fc.MaxSpeed <= fc.MinSpeed {
+		return fc, fmt.Errorf("no usable range: spins at %d%%, saturated at %d%%", fc.MinSpeed, fc.MaxSpeed)
+	}
+	return fc, nil
+}
+
+// waitSettled polls until calibrateStablePolls RPM readings in a row agree and
+// returns the settled value and how long after the command it first appeared.
+func waitSettled(ctx context.Context, read func() (int, nvml.Return), opts calibrateOptions) (int, float64, error) {
+	start := time.Now()
+	ticker := time.NewTicker(opts.poll)
+	defer ticker.Stop()
+
+	var (
+		last, stable int
+		stableSince  time.Time
+	)
+	for {
+		select {
+		case <-ctx.Done():
+			return 0, 0, fmt.Errorf("interrupted")
+		case now := <-ticker.C:
+			value, ret := read()
+			if ret != nvml.SUCCESS {
+				return 0, 0, fmt.Errorf("unable to read fan: %v", nvml.ErrorString(ret))
+			}
+			tolerance := 0
+			if value > 0 {
+				tolerance = int(math.Max(calibrateToleranceRPM, calibrateToleranceShare*float64(value)))
+			}
+			if stable > 0 && abs(value-last) <= tolerance {
+				stable++
+			} else {
+				stable, stableSince = 1, now
+			}
+			last = value
+			if stable >= calibrateStablePolls {
+				return value, stableSince.Sub(start).Seconds(), nil
+			}
+			if now.Sub(start) > opts.settleTimeout {
+				return value, now.Sub(start).Seconds(), fmt.Errorf("reading did not settle within %s (last %d)", opts.settleTimeout, value)
+			}
+		}
+	}
+}
+
+func printFanCalibration(label string, fc FanCalibration) {
+	tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintf(tw, "speed_pct\t%s\tsettle_s\t\n", fc.Source)
+	for _, pt := range fc.Points {
+		if pt.Rejected {
+			fmt.Fprintf(tw, "%d\trejected\t-\t\n", pt.Speed)
+			continue
+		}
+		fmt.Fprintf(tw, "%d\t%d\t%.2f\t\n", pt.Speed, pt.Value, pt.SettleSeconds)
+	}
+	_ = tw.Flush()
+	fmt.Printf("%s Fan %d: spins from %d%%, saturates at %d%%, settles within %.1fs\n",
+		label, fc.Fan, fc.MinSpeed, fc.MaxSpeed, fc.SettleSeconds)
+}
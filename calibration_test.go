@@ -0,0 +1,101 @@
+package main
+
+import (
+	"context"
+	"io"
+	"log"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/NVIDIA/go-nvml/pkg/nvml"
+)
+
+// noTachDevice is a device without RPM readings, like NVML in this build.
+type noTachDevice struct {
+	Device
+}
+
+func (noTachDevice) FanSpeedRPM(fanIdx int) (int, nvml.Return) {
+	return 0, nvml.ERROR_FUNCTION_NOT_FOUND
+}
+
+func newCalibrationSim(t *testing.T) *simDevice {
+	t.Helper()
+	log.SetOutput(io.Discard)
+	t.Cleanup(func() { log.SetOutput(os.Stderr) })
+	b, err := newSimBackend(1, 1, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dev := b.devices[0]
+	dev.rpmLag = 5 * time.Millisecond
+	return dev
+}
+
+func TestCalibrateFanSim(t *testing.T) {
+	tests := []struct {
+		step     int
+		min, max int
+	}{
+		// The simulated fan stalls below 28% and saturates at 85%.
+		{step: 5, min: 30, max: 85},
+		{step: 10, min: 30, max: 90},
+		{step: 25, min: 45, max: 95},
+	}
+	for _, tt := range tests {
+		dev := newCalibrationSim(t)
+		lo, hi, _ := dev.MinMaxFanSpeed()
+		opts := calibrateOptions{step: tt.step, poll: time.Millisecond, settleTimeout: 2 * time.Second}
+		fc, err := calibrateFan(context.Background(), dev, 0, lo, hi, opts)
+		if err != nil {
+			t.Fatalf("step %d: %v", tt.step, err)
+		}
+		if fc.MinSpeed != tt.min || fc.MaxSpeed != tt.max {
+			t.Errorf("step %d: range %d..%d%%, want %d..%d%%", tt.step, fc.MinSpeed, fc.MaxSpeed, tt.min, tt.max)
+		}
+		if fc.Source != "rpm" || fc.SettleSeconds <= 0 {
+			t.Errorf("step %d: source %q, settle %.3fs; want rpm and a measured settle time", tt.step, fc.Source, fc.SettleSeconds)
+		}
+		if last := fc.Points[len(fc.Points)-1]; last.Speed != hi {
+			t.Errorf("step %d: sweep ended at %d%%, want %d%%", tt.step, last.Speed, hi)
+		}
+	}
+}
+
+func TestCalibrateFanRefusesWithoutTachometer(t *testing.T) {
+	sim := newCalibrationSim(t)
+	dev := noTachDevice{sim}
+	opts := calibrateOptions{step: 5, poll: time.Millisecond, settleTimeout: time.Second}
+	if _, err := calibrateFan(context.Background(), dev, 0, 20, 100, opts); err == nil {
+		t.Fatal("expected an error without RPM readings")
+	}
+	if sim.Policy(0) != nvml.FAN_POLICY_TEMPERATURE_CONTINOUS_SW {
+		t.Fatal("fan was taken over although calibration refused")
+	}
+}
+
+func TestCalibratedDeviceMapping(t *testing.T) {
+	sim := newCalibrationSim(t)
+	dev := calibratedDevice{Device: sim, fans: map[int]FanCalibration{0: {MinSpeed: 30, MaxSpeed: 80}}}
+	sim.SetFanControlPolicy(0, nvml.FAN_POLICY_MANUAL)
+	tests := []struct {
+		speed, sent int
+	}{
+		{0, 0},
+		{1, 31},
+		{50, 55},
+		{100, 80},
+	}
+	for _, tt := range tests {
+		if ret := dev.SetFanSpeed(0, tt.speed); ret != nvml.SUCCESS {
+			t.Fatalf("SetFanSpeed(%d): %v", tt.speed, nvml.ErrorString(ret))
+		}
+		if got, _ := sim.FanSpeed(0); got != tt.sent {
+			t.Errorf("%d%% sent as %d%%, want %d%%", tt.speed, got, tt.sent)
+		}
+		if back, _ := dev.FanSpeed(0); abs(back-tt.speed) > 1 {
+			t.Errorf("%d%% reads back as %d%%", tt.speed, back)
+		}
+	}
+}
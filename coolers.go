@@ -0,0 +1,212 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/NVIDIA/go-nvml/pkg/nvml"
+)
+
+// ---------- Cooler targets (which sensor each fan should follow) ----------
+
+// coolerTarget is what a cooler is meant to cool, with the bit values of
+// NVML's nvmlCoolerTarget_t.
+type coolerTarget uint32
+
+const (
+	coolerTargetNone        coolerTarget = 1 << 0
+	coolerTargetGPU         coolerTarget = 1 << 1
+	coolerTargetMemory      coolerTarget = 1 << 2
+	coolerTargetPowerSupply coolerTarget = 1 << 3
+	coolerTargetAll                      = coolerTargetGPU | coolerTargetMemory | coolerTargetPowerSupply
+)
+
+func (t coolerTarget) String() string {
+	if t&coolerTargetAll == coolerTargetAll {
+		return "all"
+	}
+	var parts []string
+	for _, p := range []struct {
+		bit  coolerTarget
+		name string
+	}{{coolerTargetGPU, "gpu"}, {coolerTargetMemory, "memory"}, {coolerTargetPowerSupply, "power supply"}} {
+		if t&p.bit != 0 {
+			parts = append(parts, p.name)
+		}
+	}
+	if len(parts) == 0 {
+		return "none"
+	}
+	return strings.Join(parts, "+")
+}
+
+// parseCoolerTarget accepts the names String produces (and "mem", "psu").
+func parseCoolerTarget(s string) (coolerTarget, error) {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "gpu":
+		return coolerTargetGPU, nil
+	case "memory", "mem":
+		return coolerTargetMemory, nil
+	case "power supply", "psu":
+		return coolerTargetPowerSupply, nil
+	case "all":
+		return coolerTargetAll, nil
+	case "none":
+		return coolerTargetNone, nil
+	}
+	return 0, fmt.Errorf("unknown cooler target %q (expected gpu|memory|psu|all|none)", s)
+}
+
+// coolerInfo is one fan's nvmlCoolerInfo_t: its target and whether it can
+// only be switched on/off ("toggle") or also run at a speed ("variable").
+type coolerInfo struct {
+	target  coolerTarget
+	control string
+}
+
+func (ci coolerInfo) String() string {
+	return fmt.Sprintf("cools %s (%s control)", ci.target, ci.control)
+}
+
+// logCoolerInfo logs what each fan of a device is meant to cool.
+func logCoolerInfo(dev Device, label string, fans int) {
+	for fanIdx := 0; fanIdx < fans; fanIdx++ {
+		ci, ret := dev.CoolerInfo(fanIdx)
+		if ret == nvml.ERROR_FUNCTION_NOT_FOUND {
+			// Only a multi-fan card loses anything: its memory fan can't be mapped.
+			level := "INFO"
+			if fans > 1 {
+				level = "WARN"
+			}
+			log.Printf("%s: %s: cooler targets are not supported by this build (go-nvml v0.12.4 has no nvmlDeviceGetCoolerInfo), so cooler_targets has no effect; all %d fan(s) follow the device input unless a fans override sets a sensor.",
+				level, label, fans)
+			return
+		}
+		if ret != nvml.SUCCESS {
+			log.Printf("INFO: %s: cooler target info unavailable (%v); fans follow the device input unless a fans override sets a sensor.",
+				label, nvml.ErrorString(ret))
+			return
+		}
+		log.Printf("INFO: %s Fan %d %s.", label, fanIdx, ci)
+	}
+}
+
+// fanInputFor picks the input fan fanIdx follows and says why: the sensor of
+// its fans override, else the one matching its cooler target. nil means the
+// device input.
+func fanInputFor(s deviceSettings, dev Device, fanIdx int) (*sensorExpr, string) {
+	if o, ok := s.fans[fanIdx]; ok && o.Sensor != "" {
+		if e, err := parseSensorExpr(o.Sensor); err == nil {
+			return e, "fans override"
+		}
+	}
+	if s.coolerTargets != nil && !*s.coolerTargets || dev == nil {
+		return nil, ""
+	}
+	ci, ret := dev.CoolerInfo(fanIdx)
+	if ret != nvml.SUCCESS {
+		return nil, ""
+	}
+	// Only a dedicated memory cooler gets its own input: a GPU, shared or
+	// power supply cooler (there is no PSU sensor) follows the device.
+	if ci.target&coolerTargetMemory != 0 && ci.target&coolerTargetGPU == 0 {
+		return &sensorExpr{sensor: "mem"}, "cooler target " + ci.target.String()
+	}
+	return nil, ""
+}
+
+// describeCooler is the cooler part of a fan's status line.
+func describeCooler(dev Device, fanIdx int) string {
+	ci, ret := dev.CoolerInfo(fanIdx)
+	if ret == nvml.ERROR_FUNCTION_NOT_FOUND {
+		return "cooler target not read (no nvmlDeviceGetCoolerInfo in this build)"
+	}
+	if ret != nvml.SUCCESS {
+		return fmt.Sprintf("cooler target unknown (%v)", nvml.ErrorString(ret))
+	}
+	return ci.String()
+}
+
+// resolveFanInputs picks, probes and logs the fans of device i that follow a
+// sensor of their own.
+func (c *fanController) resolveFanInputs(i int, dev Device) []*sensorExpr {
+	s := c.settings[i]
+	out := make([]*sensorExpr, c.fanCounts[i])
+	if dev == nil {
+		return out
+	}
+	for fanIdx := range out {
+		e, why := fanInputFor(s, dev, fanIdx)
+		if e == nil {
+			continue
+		}
+		fanLabel := fmt.Sprintf("%s Fan %d", s.label, fanIdx)
+		if s.usePID {
+			log.Printf("INFO: %s: own input %s (%s) is ignored in PID mode; it follows the device.", fanLabel, e, why)
+			continue
+		}
+		if e = probeSensorExpr(fanLabel, e, dev); e == nil {
+			log.Printf("WARN: %s: follows the device input instead.", fanLabel)
+			continue
+		}
+		out[fanIdx] = e
+		log.Printf("INFO: %s follows %s (%s).", fanLabel, e, why)
+	}
+	return out
+}
+
+// readFanInputs reads the fans of device i that have their own input. core
+// is this update's core reading; on failure it names the failed sensor.
+func (c *fanController) readFanInputs(i int, device Device, core int) (string, nvml.Return) {
+	for fanIdx, e := range c.fanInputs[i] {
+		if e == nil {
+			continue
+		}
+		temp, failed, ret := e.eval(func(sensor string) (int, nvml.Return) {
+			if sensor == "core" {
+				return core, nvml.SUCCESS
+			}
+			return readSensor(device, sensor)
+		})
+		if ret != nvml.SUCCESS {
+			return failed, ret
+		}
+		c.fanTemps[i][fanIdx] = temp
+	}
+	return "", nvml.SUCCESS
+}
+
+// fanInputsMoved reports whether any fan with its own input moved at least
+// hyst since its speed was last set, so the curve hysteresis lets it update.
+func (c *fanController) fanInputsMoved(i, hyst int) bool {
+	for fanIdx, e := range c.fanInputs[i] {
+		if e != nil && abs(c.fanTemps[i][fanIdx]-c.fanChangeTemps[i][fanIdx]) >= hyst {
+			return true
+		}
+	}
+	return false
+}
+
+// fanInputTarget is the curve-mode target of a fan following its own input
+// at temp. The floor is a device decision, so below the first point the fan
+// runs that point's speed.
+func (c *fanController) fanInputTarget(i, temp int) int {
+	prof := c.settings[i].prof
+	speed, _ := curveSpeedForTempWithProfile(temp, prof)
+	if len(prof.points) > 0 && temp < prof.points[0].temp {
+		speed = prof.points[0].speed
+	}
+	return c.combineFeedForward(i, speed)
+}
+
+// fanInputDetail lists the own-input fans' temperatures for update log lines, or "".
+func (c *fanController) fanInputDetail(i int) string {
+	var out string
+	for fanIdx, e := range c.fanInputs[i] {
+		if e != nil {
+			out += fmt.Sprintf(", Fan %d %s=%d°C", fanIdx, e, c.fanTemps[i][fanIdx])
+		}
+	}
+	return out
+}
@@ -0,0 +1,219 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+)
+
+// ---------- Explicit curve format (curve_points) ----------
+
+// CurveConfig describes a curve directly instead of through temperature_ranges.
+type CurveConfig struct {
+	Floor  CurveFloorConfig   `json:"floor"`
+	Points []CurvePointConfig `json:"points"`
+}
+
+// CurveFloorConfig is what happens below the first point.
+type CurveFloorConfig struct {
+	Temperature int    `json:"temperature"`          // floor applies below this temperature
+	Policy      string `json:"policy,omitempty"`     // "auto" (hand fans to the driver, default) or "speed"
+	Speed       int    `json:"speed,omitempty"`      // policy "speed": fixed speed below the floor
+	Hysteresis  int    `json:"hysteresis,omitempty"` // deadband around the floor temperature
+}
+
+type CurvePointConfig struct {
+	Temp       int `json:"temp"`
+	Speed      int `json:"speed"`
+	Hysteresis int `json:"hysteresis,omitempty"` // applies from this point up to the next
+}
+
+// validate rejects curves the daemon would have to guess about.
+func (cc CurveConfig) validate() error {
+	f := cc.Floor
+	switch f.Policy {
+	case "", "auto":
+	case "speed":
+		if f.Speed < 0 || f.Speed > 100 {
+			return fmt.Errorf("floor speed must be 0..100 (got %d)", f.Speed)
+		}
+	default:
+		return fmt.Errorf("unknown floor policy %q (expected auto|speed)", f.Policy)
+	}
+	if f.Hysteresis < 0 {
+		return fmt.Errorf("floor hysteresis must be >= 0 (got %d)", f.Hysteresis)
+	}
+	if len(cc.Points) == 0 {
+		return fmt.Errorf("points must not be empty")
+	}
+	for i, p := range cc.Points {
+		if p.Speed < 0 || p.Speed > 100 {
+			return fmt.Errorf("point %d: speed must be 0..100 (got %d)", i, p.Speed)
+		}
+		if p.Hysteresis < 0 {
+			return fmt.Errorf("point %d: hysteresis must be >= 0 (got %d)", i, p.Hysteresis)
+		}
+		if i > 0 && p.Temp <= cc.Points[i-1].Temp {
+			return fmt.Errorf("point %d: temp %d°C must be above the previous point (%d°C)", i, p.Temp, cc.Points[i-1].Temp)
+		}
+	}
+	if f.Temperature > cc.Points[0].Temp {
+		return fmt.Errorf("floor temperature %d°C is above the first point (%d°C)", f.Temperature, cc.Points[0].Temp)
+	}
+	return nil
+}
+
+// profile converts a validated curve to the form the control loop evaluates.
+func (cc CurveConfig) profile() curveProfile {
+	prof := curveProfile{
+		floorEndTemp: cc.Floor.Temperature,
+		floorHyst:    cc.Floor.Hysteresis,
+		floorManual:  cc.Floor.Policy == "speed",
+		floorSpeed:   cc.Floor.Speed,
+		points:       make([]curvePoint, len(cc.Points)),
+	}
+	for i, p := range cc.Points {
+		prof.points[i] = curvePoint{temp: p.Temp, speed: p.Speed, hyst: p.Hysteresis}
+	}
+	return prof
+}
+
+// config is the inverse of profile; used to show what temperature_ranges became.
+func (prof curveProfile) config() CurveConfig {
+	cc := CurveConfig{
+		Floor:  CurveFloorConfig{Temperature: prof.floorEndTemp, Policy: "auto", Hysteresis: prof.floorHyst},
+		Points: make([]CurvePointConfig, len(prof.points)),
+	}
+	if prof.floorManual {
+		cc.Floor.Policy, cc.Floor.Speed = "speed", prof.floorSpeed
+	}
+	for i, p := range prof.points {
+		cc.Points[i] = CurvePointConfig{Temp: p.temp, Speed: p.speed, Hysteresis: p.hyst}
+	}
+	return cc
+}
+
+func (prof curveProfile) String() string {
+	data, err := json.Marshal(prof.config())
+	if err != nil {
+		return err.Error()
+	}
+	return string(data)
+}
+
+// describeFloor is the floor part of the mode log lines.
+func (prof curveProfile) describeFloor() string {
+	if prof.floorManual {
+		return fmt.Sprintf("floor(<%d°C)=%d%%", prof.floorEndTemp, prof.floorSpeed)
+	}
+	return fmt.Sprintf("floor(<%d°C)=AUTO", prof.floorEndTemp)
+}
+
+// ---------- Interpolation between curve points ----------
+
+// validInterpolation accepts "" (linear), "linear", "monotone" and "step".
+func validInterpolation(kind string) error {
+	switch kind {
+	case "", "linear", "monotone", "step":
+		return nil
+	}
+	return fmt.Errorf("unknown interpolation %q (expected linear|monotone|step)", kind)
+}
+
+// setInterpolation selects how speeds between points are computed. For
+// "monotone" it precomputes Fritsch–Carlson tangents, which keep the curve
+// within each segment's speeds: no overshoot, and never falling where the
+// points rise.
+func (prof *curveProfile) setInterpolation(kind string) {
+	if kind == "" {
+		kind = "linear"
+	}
+	prof.interp = kind
+	prof.tangents = nil
+	n := len(prof.points)
+	if kind != "monotone" || n < 2 {
+		return
+	}
+
+	secants := make([]float64, n-1)
+	for k := 0; k < n-1; k++ {
+		a, b := prof.points[k], prof.points[k+1]
+		secants[k] = float64(b.speed-a.speed) / float64(b.temp-a.temp)
+	}
+	m := make([]float64, n)
+	m[0], m[n-1] = secants[0], secants[n-2]
+	for k := 1; k < n-1; k++ {
+		if secants[k-1]*secants[k] > 0 {
+			m[k] = (secants[k-1] + secants[k]) / 2
+		}
+	}
+	for k := 0; k < n-1; k++ {
+		if secants[k] == 0 {
+			m[k], m[k+1] = 0, 0
+			continue
+		}
+		a, b := m[k]/secants[k], m[k+1]/secants[k]
+		if s := a*a + b*b; s > 9 {
+			tau := 3 / math.Sqrt(s)
+			m[k], m[k+1] = tau*a*secants[k], tau*b*secants[k]
+		}
+	}
+	prof.tangents = m
+}
+
+// interpolate returns the speed at temp within segment k (points[k] <= temp < points[k+1]).
+func (prof curveProfile) interpolate(k, temp int) int {
+	a, b := prof.points[k], prof.points[k+1]
+	switch prof.interp {
+	case "step":
+		return a.speed
+	case "monotone":
+		if prof.tangents != nil {
+			h := float64(b.temp - a.temp)
+			t := float64(temp-a.temp) / h
+			t2, t3 := t*t, t*t*t
+			val := (2*t3-3*t2+1)*float64(a.speed) + (t3-2*t2+t)*h*prof.tangents[k] +
+				(-2*t3+3*t2)*float64(b.speed) + (t3-t2)*h*prof.tangents[k+1]
+			return clampInt(int(math.Round(val)), 0, 100)
+		}
+	}
+	t := float64(temp-a.temp) / float64(b.temp-a.temp)
+	return clampInt(int(math.Round(float64(a.speed)+t*float64(b.speed-a.speed))), 0, 100)
+}
+
+// validateCurves checks every curve_points object and interpolation in the config.
+func validateCurves(config Config) error {
+	if err := validInterpolation(config.Interpolation); err != nil {
+		return err
+	}
+	if config.CurvePoints != nil {
+		if err := config.CurvePoints.validate(); err != nil {
+			return fmt.Errorf("curve_points: %w", err)
+		}
+	}
+	for key, dc := range config.Devices {
+		if dc.Interpolation != nil {
+			if err := validInterpolation(*dc.Interpolation); err != nil {
+				return fmt.Errorf("devices[%q]: %w", key, err)
+			}
+		}
+		if dc.CurvePoints != nil {
+			if err := dc.CurvePoints.validate(); err != nil {
+				return fmt.Errorf("devices[%q].curve_points: %w", key, err)
+			}
+		}
+	}
+	for _, hf := range config.HwmonFans {
+		if hf.Interpolation != nil {
+			if err := validInterpolation(*hf.Interpolation); err != nil {
+				return fmt.Errorf("hwmon_fans[%q]: %w", hf.Name, err)
+			}
+		}
+		if hf.CurvePoints != nil {
+			if err := hf.CurvePoints.validate(); err != nil {
+				return fmt.Errorf("hwmon_fans[%q].curve_points: %w", hf.Name, err)
+			}
+		}
+	}
+	return nil
+}
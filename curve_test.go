@@ -0,0 +1,198 @@
+package main
+
+import "testing"
+
+func testCurve(interp string, pts ...[2]int) curveProfile {
+	prof := curveProfile{}
+	for _, p := range pts {
+		prof.points = append(prof.points, curvePoint{temp: p[0], speed: p[1]})
+	}
+	prof.setInterpolation(interp)
+	return prof
+}
+
+func TestCurveInterpolation(t *testing.T) {
+	prof := func(interp string) curveProfile {
+		return testCurve(interp, [2]int{40, 30}, [2]int{60, 50}, [2]int{80, 100})
+	}
+	tests := []struct {
+		interp string
+		temp   int
+		want   int
+	}{
+		{"", 50, 40},
+		{"linear", 50, 40},
+		{"linear", 70, 75},
+		{"step", 59, 30},
+		{"step", 60, 50},
+		{"step", 79, 50},
+		{"monotone", 40, 30},
+		{"monotone", 60, 50},
+		{"monotone", 80, 100},
+		{"monotone", 90, 100},
+	}
+	for _, tt := range tests {
+		if got, _ := curveSpeedForTempWithProfile(tt.temp, prof(tt.interp)); got != tt.want {
+			t.Errorf("%s at %d°C: %d%%, want %d%%", tt.interp, tt.temp, got, tt.want)
+		}
+	}
+}
+
+// TestMonotoneCurveNeverOvershoots sweeps monotone curves degree by degree:
+// every speed stays within its segment's endpoints, and a curve whose points
+// never fall never decreases.
+func TestMonotoneCurveNeverOvershoots(t *testing.T) {
+	tests := []struct {
+		name   string
+		points [][2]int
+	}{
+		{"gentle", [][2]int{{30, 20}, {50, 40}, {70, 60}, {90, 100}}},
+		{"plateau", [][2]int{{30, 20}, {40, 60}, {60, 60}, {65, 100}}},
+		{"steep step", [][2]int{{40, 30}, {41, 90}, {80, 100}}},
+		{"flat then steep", [][2]int{{30, 30}, {60, 31}, {62, 100}}},
+		{"uneven spacing", [][2]int{{20, 0}, {21, 5}, {70, 6}, {71, 100}}},
+		{"two points", [][2]int{{40, 25}, {85, 100}}},
+		{"falling points", [][2]int{{40, 80}, {60, 40}, {70, 45}, {90, 100}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			prof := testCurve("monotone", tt.points...)
+			rising := true
+			for k := 1; k < len(tt.points); k++ {
+				rising = rising && tt.points[k][1] >= tt.points[k-1][1]
+			}
+			first, last := tt.points[0][0], tt.points[len(tt.points)-1][0]
+			prev := -1
+			for temp := first; temp <= last; temp++ {
+				got, _ := curveSpeedForTempWithProfile(temp, prof)
+				for k := 0; k+1 < len(tt.points); k++ {
+					a, b := tt.points[k], tt.points[k+1]
+					if temp < a[0] || temp > b[0] {
+						continue
+					}
+					lo, hi := a[1], b[1]
+					if lo > hi {
+						lo, hi = hi, lo
+					}
+					if got < lo || got > hi {
+						t.Fatalf("%d°C: %d%% outside the segment's %d..%d%%", temp, got, lo, hi)
+					}
+				}
+				if rising && got < prev {
+					t.Fatalf("%d°C: %d%% is below %d%% at %d°C", temp, got, prev, temp-1)
+				}
+				prev = got
+			}
+		})
+	}
+}
+
+func TestCurveConfigValidate(t *testing.T) {
+	points := []CurvePointConfig{{Temp: 50, Speed: 40}, {Temp: 70, Speed: 80}}
+	tests := []struct {
+		name    string
+		cfg     CurveConfig
+		wantErr bool
+	}{
+		{"auto floor", CurveConfig{Floor: CurveFloorConfig{Temperature: 45}, Points: points}, false},
+		{"speed floor", CurveConfig{Floor: CurveFloorConfig{Temperature: 45, Policy: "speed", Speed: 20}, Points: points}, false},
+		{"floor at the first point", CurveConfig{Floor: CurveFloorConfig{Temperature: 50}, Points: points}, false},
+		{"floor above the first point", CurveConfig{Floor: CurveFloorConfig{Temperature: 55}, Points: points}, true},
+		{"unknown floor policy", CurveConfig{Floor: CurveFloorConfig{Temperature: 45, Policy: "off"}, Points: points}, true},
+		{"floor speed above 100", CurveConfig{Floor: CurveFloorConfig{Temperature: 45, Policy: "speed", Speed: 101}, Points: points}, true},
+		{"negative floor hysteresis", CurveConfig{Floor: CurveFloorConfig{Temperature: 45, Hysteresis: -1}, Points: points}, true},
+		{"no points", CurveConfig{Floor: CurveFloorConfig{Temperature: 45}}, true},
+		{"point speed above 100", CurveConfig{Points: []CurvePointConfig{{Temp: 50, Speed: 120}}}, true},
+		{"negative point hysteresis", CurveConfig{Points: []CurvePointConfig{{Temp: 50, Speed: 40, Hysteresis: -2}}}, true},
+		{"points out of order", CurveConfig{Points: []CurvePointConfig{{Temp: 70, Speed: 80}, {Temp: 50, Speed: 40}}}, true},
+		{"duplicate point temperature", CurveConfig{Points: []CurvePointConfig{{Temp: 50, Speed: 40}, {Temp: 50, Speed: 60}}}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.cfg.validate(); (err != nil) != tt.wantErr {
+				t.Fatalf("err=%v, want error %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestLoadConfigurationValidatesCurvePoints(t *testing.T) {
+	const bad = `{"floor": {"temperature": 60}, "points": [{"temp": 50, "speed": 40}]}`
+	tests := []struct {
+		name   string
+		config string
+	}{
+		{"top level", `{"curve_points": ` + bad + `}`},
+		{"devices entry", `{"devices": {"0": {"curve_points": ` + bad + `}}}`},
+		{"hwmon_fans entry", `{"hwmon_fans": [{"name": "case", "chip": "nct6798", "pwm": 1, "curve_points": ` + bad + `}]}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := loadTestConfig(t, tt.config); err == nil {
+				t.Fatal("expected the config to be rejected")
+			}
+		})
+	}
+}
+
+func TestCurveSpeedAroundTheFloor(t *testing.T) {
+	auto := CurveConfig{
+		Floor:  CurveFloorConfig{Temperature: 40, Hysteresis: 2},
+		Points: []CurvePointConfig{{Temp: 55, Speed: 40, Hysteresis: 3}, {Temp: 80, Speed: 100}},
+	}
+	speed := auto
+	speed.Floor.Policy, speed.Floor.Speed = "speed", 25
+	tests := []struct {
+		name      string
+		cfg       CurveConfig
+		temp      int
+		want      int
+		wantHyst  int
+		wantFloor bool
+	}{
+		{"below the auto floor", auto, 39, 0, 2, true},
+		{"gap above the auto floor", auto, 47, 40, 3, false},
+		{"gap at the auto floor", auto, 40, 40, 3, false},
+		{"first point", auto, 55, 40, 3, false},
+		{"between points", auto, 60, 52, 3, false},
+		{"above the last point", auto, 90, 100, 0, false},
+		{"below the speed floor", speed, 39, 25, 2, true},
+		{"gap above the speed floor", speed, 47, 40, 3, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			prof := tt.cfg.profile()
+			prof.setInterpolation("linear")
+			got, hyst := curveSpeedForTempWithProfile(tt.temp, prof)
+			if got != tt.want || hyst != tt.wantHyst {
+				t.Fatalf("%d°C: %d%% (hyst %d), want %d%% (hyst %d)", tt.temp, got, hyst, tt.want, tt.wantHyst)
+			}
+			if floor := tt.temp < prof.floorEndTemp; floor != tt.wantFloor {
+				t.Fatalf("%d°C below the floor: %v, want %v", tt.temp, floor, tt.wantFloor)
+			}
+		})
+	}
+}
+
+// TestCurveGapKeepsFansRunning drives the control loop in the gap between an
+// AUTO floor and the first point: the fans run at the first point's speed.
+func TestCurveGapKeepsFansRunning(t *testing.T) {
+	sim := newTestSim(t, 1, 1, []int{35})
+	sim.setFanSpeedRange(0, 100)
+	c := newTestController(t, `{
+		"time_to_update": 5,
+		"fan_speed_range": "off",
+		"curve_points": {
+			"floor": {"temperature": 40},
+			"points": [{"temp": 55, "speed": 40}, {"temp": 80, "speed": 100}]
+		}
+	}`, sim)
+	sim.devices[0].SetTemperature(47)
+	c.tick()
+	if c.inAuto[0] {
+		t.Fatal("still in AUTO above the floor")
+	}
+	if got, _ := sim.devices[0].FanSpeed(0); got != 40 {
+		t.Fatalf("fan at %d%% at 47°C, want the first point's 40%%", got)
+	}
+}
@@ -0,0 +1,272 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// ---------- Per-GPU / per-model configuration sections ----------
+
+// DeviceConfig overrides the top-level control settings for one device.
+// Unset fields fall back to the top-level config.
+type DeviceConfig struct {
+	Curve             *bool               `json:"curve,omitempty"`
+	TemperatureRanges []TemperatureRange  `json:"temperature_ranges,omitempty"`
+	CurvePoints       *CurveConfig        `json:"curve_points,omitempty"`      // explicit curve, implies curve mode
+	Interpolation     *string             `json:"interpolation,omitempty"`     // curve mode: linear|monotone|step
+	FloorTemperature  *int                `json:"floor_temperature,omitempty"` // curve mode: AUTO below this temperature
+	FloorHysteresis   *int                `json:"floor_hysteresis,omitempty"`  // curve mode: deadband around the floor
+	Hysteresis        *int                `json:"hysteresis,omitempty"`        // replaces every range's hysteresis
+	PID               *PIDConfig          `json:"pid,omitempty"`               // PID mode (overrides curve)
+	RampUpPerSecond   *float64            `json:"ramp_up_per_second,omitempty"`
+	RampDownPerSecond *float64            `json:"ramp_down_per_second,omitempty"`
+	EmergencyTemp     *int                `json:"emergency_temperature,omitempty"`
+	EmergencyRecovery *int                `json:"emergency_recovery_temperature,omitempty"`
+	EmergencyMargin   *int                `json:"emergency_slowdown_margin,omitempty"`
+	TemperatureFilter *FilterConfig       `json:"temperature_filter,omitempty"`
+	Sensor            *string             `json:"sensor,omitempty"`          // control input: core|mem|hotspot or a max/min composite
+	CoolerTargets     *bool               `json:"cooler_targets,omitempty"`  // memory coolers follow the memory sensor
+	FanSpeedRange     *string             `json:"fan_speed_range,omitempty"` // fit commands into the device min/max: clamp|scale|off
+	FanStop           *FanStopConfig      `json:"fan_stop,omitempty"`
+	FeedForward       *FeedForwardConfig  `json:"feed_forward,omitempty"`
+	SpinDown          *SpinDownConfig     `json:"spin_down,omitempty"`
+	Fans              map[int]FanOverride `json:"fans,omitempty"` // per-fan offset/scale/clamps/curve, keyed by fan index
+}
+
+// selectorRank orders section keys so the most specific match wins:
+// uuid, then pci, then index, then name patterns (longest first).
+func selectorRank(key string) int {
+	kind, _, ok := strings.Cut(strings.TrimSpace(key), ":")
+	if !ok {
+		if _, err := strconv.Atoi(strings.TrimSpace(key)); err == nil {
+			return 2
+		}
+		return 3
+	}
+	switch strings.ToLower(kind) {
+	case "uuid":
+		return 0
+	case "pci":
+		return 1
+	case "index":
+		return 2
+	default:
+		return 3
+	}
+}
+
+// matchDeviceSection returns the key and section of config.Devices that applies
+// to the device with this index/identity, or ok=false if none does.
+func matchDeviceSection(sections map[string]DeviceConfig, idx int, id deviceIdentity) (key string, dc DeviceConfig, ok bool) {
+	keys := make([]string, 0, len(sections))
+	for k := range sections {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		ri, rj := selectorRank(keys[i]), selectorRank(keys[j])
+		if ri != rj {
+			return ri < rj
+		}
+		if len(keys[i]) != len(keys[j]) {
+			return len(keys[i]) > len(keys[j])
+		}
+		return keys[i] < keys[j]
+	})
+
+	for _, k := range keys {
+		matched, err := GPUSelector(k).matches(idx, id)
+		if err != nil {
+			log.Printf("WARN: Ignoring devices section %q: %v", k, err)
+			continue
+		}
+		if matched {
+			return k, sections[k], true
+		}
+	}
+	return "", DeviceConfig{}, false
+}
+
+// apply layers a section over s; anything set marks the settings as custom.
+func (s *deviceSettings) apply(dc DeviceConfig) {
+	if len(dc.TemperatureRanges) > 0 {
+		s.ranges = dc.TemperatureRanges
+		s.custom = true
+	}
+	if dc.Curve != nil {
+		s.curve = *dc.Curve
+		s.custom = true
+	}
+	if dc.CurvePoints != nil {
+		s.curvePoints = dc.CurvePoints
+		s.custom = true
+	}
+	if dc.Interpolation != nil {
+		s.interpolation = *dc.Interpolation
+		s.custom = true
+	}
+	if dc.FloorTemperature != nil {
+		s.floorTemp = dc.FloorTemperature
+		s.custom = true
+	}
+	if dc.FloorHysteresis != nil {
+		s.floorHyst = dc.FloorHysteresis
+		s.custom = true
+	}
+	if dc.Hysteresis != nil {
+		s.hyst = dc.Hysteresis
+		s.custom = true
+	}
+	if dc.PID != nil {
+		s.pid = dc.PID
+		s.custom = true
+	}
+	if dc.RampUpPerSecond != nil {
+		s.rampUp = *dc.RampUpPerSecond
+		s.custom = true
+	}
+	if dc.RampDownPerSecond != nil {
+		s.rampDown = *dc.RampDownPerSecond
+		s.custom = true
+	}
+	if dc.EmergencyTemp != nil {
+		s.emergencyTemp = dc.EmergencyTemp
+		s.custom = true
+	}
+	if dc.EmergencyRecovery != nil {
+		s.emergencyRecovery = dc.EmergencyRecovery
+		s.custom = true
+	}
+	if dc.EmergencyMargin != nil {
+		s.emergencyMargin = dc.EmergencyMargin
+		s.custom = true
+	}
+	if dc.TemperatureFilter != nil {
+		s.filter = dc.TemperatureFilter
+		s.custom = true
+	}
+	if dc.Sensor != nil {
+		s.sensor = *dc.Sensor
+		s.custom = true
+	}
+	if dc.CoolerTargets != nil {
+		s.coolerTargets = dc.CoolerTargets
+		s.custom = true
+	}
+	if dc.FanSpeedRange != nil {
+		s.fanSpeedRange = *dc.FanSpeedRange
+		s.custom = true
+	}
+	if dc.FanStop != nil {
+		s.fanStop = dc.FanStop
+		s.custom = true
+	}
+	if dc.FeedForward != nil {
+		s.feedForward = dc.FeedForward
+		s.custom = true
+	}
+	if dc.SpinDown != nil {
+		s.spinDown = dc.SpinDown
+		s.custom = true
+	}
+	if len(dc.Fans) > 0 {
+		s.fans = dc.Fans
+		s.custom = true
+	}
+}
+
+// describe summarizes the settings for status output.
+func (s deviceSettings) describe() string {
+	mode := "step"
+	if s.pid != nil {
+		mode = fmt.Sprintf("pid target=%g°C", s.pid.Target)
+	} else if s.curvePoints != nil {
+		mode = fmt.Sprintf("curve, %d point(s)", len(s.curvePoints.Points))
+	} else if s.curve {
+		mode = "curve"
+	}
+	out := fmt.Sprintf("%s, %d range(s)", mode, len(s.ranges))
+	if s.floorTemp != nil {
+		out += fmt.Sprintf(", floor=%d°C", *s.floorTemp)
+	}
+	if s.floorHyst != nil {
+		out += fmt.Sprintf(", floor hyst=%d°C", *s.floorHyst)
+	}
+	if s.hyst != nil {
+		out += fmt.Sprintf(", hyst=%d°C", *s.hyst)
+	}
+	if s.interpolation != "" {
+		out += ", " + s.interpolation + " interpolation"
+	}
+	if s.sensor != "" {
+		out += ", sensor=" + s.sensor
+	}
+	if s.filter != nil && s.filter.Type != "" {
+		out += ", filter=" + s.filter.Type
+	}
+	if s.ramped() {
+		out += ", " + s.describeRamp()
+	}
+	if s.fanSpeedRange != "" {
+		out += ", fan speed range=" + s.fanSpeedRange
+	}
+	if s.fanStop != nil {
+		out += fmt.Sprintf(", fan stop<%d°C", s.fanStop.Temperature)
+	}
+	if s.feedForward != nil {
+		out += ", feed-forward"
+	}
+	if s.spinDown != nil {
+		out += fmt.Sprintf(", spin-down hold=%ds", s.spinDown.HoldSeconds)
+	}
+	if len(s.fans) > 0 {
+		out += fmt.Sprintf(", %d fan override(s)", len(s.fans))
+	}
+	if s.emergencyTemp != nil {
+		out += fmt.Sprintf(", emergency=%d°C", *s.emergencyTemp)
+	}
+	return out
+}
+
+// topLevelSettings is the fallback every device starts from.
+func topLevelSettings(config Config) deviceSettings {
+	return deviceSettings{
+		section:           "default",
+		ranges:            config.TemperatureRanges,
+		curve:             config.Curve,
+		curvePoints:       config.CurvePoints,
+		interpolation:     config.Interpolation,
+		floorTemp:         config.FloorTemperature,
+		floorHyst:         config.FloorHysteresis,
+		hyst:              config.Hysteresis,
+		pid:               config.PID,
+		rampUp:            config.RampUpPerSecond,
+		rampDown:          config.RampDownPerSecond,
+		emergencyTemp:     config.EmergencyTemp,
+		emergencyRecovery: config.EmergencyRecovery,
+		emergencyMargin:   config.EmergencyMargin,
+		filter:            config.TemperatureFilter,
+		sensor:            config.Sensor,
+		coolerTargets:     config.CoolerTargets,
+		fanSpeedRange:     config.FanSpeedRange,
+		fanStop:           config.FanStop,
+		feedForward:       config.FeedForward,
+		spinDown:          config.SpinDown,
+	}
+}
+
+// settingsForGPU resolves the devices section for one GPU.
+func settingsForGPU(config Config, idx int, dev Device) deviceSettings {
+	s := topLevelSettings(config)
+	s.label = fmt.Sprintf("GPU %d", idx)
+	if dev == nil || len(config.Devices) == 0 {
+		return s
+	}
+	if key, dc, ok := matchDeviceSection(config.Devices, idx, identifyDevice(dev)); ok {
+		s.section = key
+		s.apply(dc)
+	}
+	return s
+}
@@ -0,0 +1,47 @@
+package main
+
+import "testing"
+
+func TestMatchDeviceSectionPrecedence(t *testing.T) {
+	id := deviceIdentity{
+		uuid: "GPU-8a1b2c3d-0000-1111-2222-333344445555",
+		pci:  "00000000:65:00.0",
+		name: "NVIDIA GeForce RTX 4090",
+	}
+	const (
+		uuid  = "uuid:GPU-8a1b2c3d-0000-1111-2222-333344445555"
+		pci   = "pci:65:00.0"
+		index = "1"
+		long  = "name:GeForce RTX 4090"
+		short = "name:4090"
+	)
+	tests := []struct {
+		name string
+		keys []string
+		idx  int
+		want string // "" = no section
+	}{
+		{"uuid beats everything", []string{short, index, pci, uuid}, 1, uuid},
+		{"pci beats index and name", []string{short, index, pci}, 1, pci},
+		{"index beats name", []string{short, long, index}, 1, index},
+		{"index: prefix ranks like an index", []string{short, "index:1"}, 1, "index:1"},
+		{"longer name pattern wins", []string{short, long}, 1, long},
+		{"a non-matching index falls through", []string{short, index}, 0, short},
+		{"a non-matching uuid falls through", []string{"uuid:GPU-ffff", pci}, 1, pci},
+		{"an invalid key is skipped", []string{"pci:65", short}, 1, short},
+		{"nothing matches", []string{"2", "name:4080"}, 1, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			quietLog(t)
+			sections := map[string]DeviceConfig{}
+			for _, k := range tt.keys {
+				sections[k] = DeviceConfig{}
+			}
+			key, _, ok := matchDeviceSection(sections, tt.idx, id)
+			if ok != (tt.want != "") || key != tt.want {
+				t.Fatalf("matched %q (ok=%v), want %q", key, ok, tt.want)
+			}
+		})
+	}
+}
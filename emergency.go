@@ -0,0 +1,140 @@
+package main
+
+import (
+	"fmt"
+	"log"
+
+	"github.com/NVIDIA/go-nvml/pkg/nvml"
+)
+
+// ---------- Emergency over-temperature failsafe ----------
+
+const (
+	defaultEmergencyMargin   = 5 // °C below the NVML slowdown threshold
+	defaultEmergencyRecovery = 5 // °C below the emergency threshold
+)
+
+// emergencyLimit is the resolved failsafe for one device; enter == 0 disables it.
+type emergencyLimit struct {
+	enter   int
+	recover int
+	source  string
+}
+
+// resolveEmergency picks the threshold from the config, or derives it from the
+// device's NVML slowdown temperature. A negative emergency_temperature disables it.
+func resolveEmergency(s deviceSettings, dev Device) (emergencyLimit, error) {
+	var lim emergencyLimit
+	switch {
+	case s.emergencyTemp != nil && *s.emergencyTemp < 0:
+		return lim, nil
+	case s.emergencyTemp != nil:
+		lim.enter = *s.emergencyTemp
+		lim.source = "configured"
+	default:
+		if dev == nil {
+			return lim, fmt.Errorf("no device handle")
+		}
+		slowdown, ret := dev.TemperatureThreshold(nvml.TEMPERATURE_THRESHOLD_SLOWDOWN)
+		if ret != nvml.SUCCESS {
+			return lim, fmt.Errorf("slowdown temperature unavailable: %v", nvml.ErrorString(ret))
+		}
+		margin := defaultEmergencyMargin
+		if s.emergencyMargin != nil {
+			margin = *s.emergencyMargin
+		}
+		lim.enter = slowdown - margin
+		lim.source = fmt.Sprintf("NVML slowdown %d°C - %d°C", slowdown, margin)
+	}
+
+	lim.recover = lim.enter - defaultEmergencyRecovery
+	if s.emergencyRecovery != nil {
+		lim.recover = *s.emergencyRecovery
+	}
+	if lim.recover > lim.enter {
+		return emergencyLimit{}, fmt.Errorf("emergency_recovery_temperature (%d°C) is above the emergency threshold (%d°C)", lim.recover, lim.enter)
+	}
+	return lim, nil
+}
+
+// validEmergencyTemp rejects emergency_temperature 0, which would otherwise
+// read as either "unset" or "disabled".
+func validEmergencyTemp(t *int) error {
+	if t != nil && *t == 0 {
+		return fmt.Errorf("emergency_temperature must not be 0: omit it for the NVML-derived threshold, or use a negative value to disable the failsafe")
+	}
+	return nil
+}
+
+// validateEmergency checks every emergency_temperature in the config.
+func validateEmergency(config Config) error {
+	if err := validEmergencyTemp(config.EmergencyTemp); err != nil {
+		return err
+	}
+	for key, dc := range config.Devices {
+		if err := validEmergencyTemp(dc.EmergencyTemp); err != nil {
+			return fmt.Errorf("devices[%q]: %w", key, err)
+		}
+	}
+	for _, hf := range config.HwmonFans {
+		if err := validEmergencyTemp(hf.EmergencyTemp); err != nil {
+			return fmt.Errorf("hwmon_fans[%q]: %w", hf.Name, err)
+		}
+	}
+	return nil
+}
+
+// checkEmergency runs the failsafe for device i. While it is active every fan
+// is held at 100% and the normal control path (hysteresis, ramp limits, the
+// gamemode lock) is skipped; it returns true in that case.
+func (c *fanController) checkEmergency(i int, device Device, temp int) bool {
+	lim := c.emergency[i]
+	if lim.enter == 0 {
+		return false
+	}
+	label := c.settings[i].label
+
+	if !c.inEmergency[i] {
+		if temp < lim.enter {
+			return false
+		}
+		c.inEmergency[i] = true
+		c.fanStop[i] = newFanStopState()
+		c.spinDown[i] = newSpinDownState() // 100% is not a peak to hold afterwards
+		log.Printf("ALERT: %s at %d°C reached the emergency threshold of %d°C: forcing all fans to 100%% until below %d°C.",
+			label, temp, lim.enter, lim.recover)
+	} else if temp < lim.recover {
+		c.inEmergency[i] = false
+		log.Printf("INFO: %s cooled to %d°C (below %d°C): leaving emergency mode, resuming normal control.", label, temp, lim.recover)
+		// Resume from the fans' real state: MANUAL at 100%.
+		c.inAuto[i] = false
+		c.rampGoals[i] = 100
+		c.resync[i] = true
+		return false
+	}
+
+	c.holdEmergency(i, device)
+	c.prevTemps[i] = temp
+	return true
+}
+
+// checkRawEmergency runs the failsafe on a core reading the filter dropped, so
+// a GPU heating faster than max_jump every update still gets 100%. A dropped
+// reading can enter or hold the emergency but never ends it.
+func (c *fanController) checkRawEmergency(i int, device Device, core int) bool {
+	if !c.inEmergency[i] {
+		return c.checkEmergency(i, device, core)
+	}
+	c.holdEmergency(i, device)
+	return true
+}
+
+// holdEmergency re-asserts 100% every update so nothing else can lower the
+// speed meanwhile.
+func (c *fanController) holdEmergency(i int, device Device) {
+	for fanIdx := 0; fanIdx < c.fanCounts[i]; fanIdx++ {
+		c.setManual(i, device, fanIdx, 100)
+	}
+	c.inAuto[i] = false
+	c.targets[i] = 100
+}
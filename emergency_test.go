@@ -0,0 +1,76 @@
+package main
+
+import "testing"
+
+func TestEmergencySeesSamplesTheFilterDrops(t *testing.T) {
+	const config = `{
+		"time_to_update": 5,
+		"curve": true,
+		"emergency_temperature": 85,
+		"temperature_filter": {"max_jump": 10},
+		"temperature_ranges": [
+			{"min_temperature": 0, "max_temperature": 40, "fan_speed": 30, "hysteresis": 3},
+			{"min_temperature": 40, "max_temperature": 200, "fan_speed": 60, "hysteresis": 3}
+		]
+	}`
+	tests := []struct {
+		name  string
+		temps []int
+		want  bool
+	}{
+		{"single jump past the threshold", []int{50, 90}, true},
+		{"heating faster than max_jump", []int{50, 62, 74, 86}, true},
+		{"dropped reading does not end it", []int{50, 90, 0}, true},
+		{"dropped low jump does not end it", []int{50, 62, 74, 86, 40}, true},
+		{"confirmed cooling ends it", []int{50, 90, 60, 62}, false},
+		{"jump below the threshold", []int{50, 70}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sim := newTestSim(t, 1, 1, []int{tt.temps[0]})
+			c := newTestController(t, config, sim)
+			for _, temp := range tt.temps {
+				sim.devices[0].SetTemperature(temp)
+				c.tick()
+			}
+			if c.inEmergency[0] != tt.want {
+				t.Fatalf("inEmergency = %v, want %v", c.inEmergency[0], tt.want)
+			}
+			if speed, _ := sim.devices[0].FanSpeed(0); tt.want && speed != 100 {
+				t.Fatalf("fan speed = %d%%, want 100%%", speed)
+			}
+		})
+	}
+}
+
+func TestEmergencyThreshold(t *testing.T) {
+	tests := []struct {
+		name        string
+		config      string
+		wantErr     bool
+		wantEnter   int
+		wantRecover int
+	}{
+		{name: "from NVML slowdown", config: `{}`, wantEnter: simSlowdownTemp - 5, wantRecover: simSlowdownTemp - 10},
+		{name: "margin", config: `{"emergency_slowdown_margin": 8}`, wantEnter: simSlowdownTemp - 8, wantRecover: simSlowdownTemp - 13},
+		{name: "configured", config: `{"emergency_temperature": 83, "emergency_recovery_temperature": 70}`, wantEnter: 83, wantRecover: 70},
+		{name: "disabled", config: `{"emergency_temperature": -1}`},
+		{name: "0 is rejected", config: `{"emergency_temperature": 0}`, wantErr: true},
+		{name: "0 in a devices section is rejected", config: `{"devices": {"0": {"emergency_temperature": 0}}}`, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sim := newTestSim(t, 1, 1, nil)
+			if _, err := loadTestConfig(t, tt.config); (err != nil) != tt.wantErr {
+				t.Fatalf("err=%v, want error %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			c := newTestController(t, tt.config, sim)
+			if lim := c.emergency[0]; lim.enter != tt.wantEnter || lim.recover != tt.wantRecover {
+				t.Fatalf("threshold %d°C, recovery %d°C; want %d°C, %d°C", lim.enter, lim.recover, tt.wantEnter, tt.wantRecover)
+			}
+		})
+	}
+}
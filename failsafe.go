@@ -0,0 +1,153 @@
+package main
+
+import (
+	"fmt"
+	"log"
+
+	"github.com/NVIDIA/go-nvml/pkg/nvml"
+)
+
+// ---------- Read-failure failsafe ----------
+
+// ReadFailsafeConfig decides what happens to a device whose readings keep failing.
+type ReadFailsafeConfig struct {
+	After  int    `json:"after"`           // consecutive failed updates before acting (default 5)
+	Action string `json:"action"`          // "auto" (hand fans to the driver, default) or "speed"
+	Speed  int    `json:"speed,omitempty"` // fixed speed for action "speed" (default 100)
+}
+
+const defaultReadFailsafeAfter = 5
+
+// resolveReadFailsafe fills in defaults; a nil config yields the defaults.
+func resolveReadFailsafe(cfg *ReadFailsafeConfig) (ReadFailsafeConfig, error) {
+	out := ReadFailsafeConfig{After: defaultReadFailsafeAfter, Action: "auto", Speed: 100}
+	if cfg == nil {
+		return out, nil
+	}
+	if cfg.After != 0 {
+		out.After = cfg.After
+	}
+	if out.After < 1 {
+		return out, fmt.Errorf("after must be >= 1 (got %d)", cfg.After)
+	}
+	switch cfg.Action {
+	case "", "auto":
+	case "speed":
+		out.Action = "speed"
+		if cfg.Speed != 0 {
+			out.Speed = cfg.Speed
+		}
+		if out.Speed < 1 || out.Speed > 100 {
+			return out, fmt.Errorf("speed must be 1..100 (got %d)", cfg.Speed)
+		}
+	default:
+		return out, fmt.Errorf("unknown action %q (expected auto|speed)", cfg.Action)
+	}
+	return out, nil
+}
+
+func (f ReadFailsafeConfig) String() string {
+	if f.Action == "speed" {
+		return fmt.Sprintf("%d%% after %d failed update(s)", f.Speed, f.After)
+	}
+	return fmt.Sprintf("AUTO after %d failed update(s)", f.After)
+}
+
+// handToDriver returns one fan to automatic control, falling back to the
+// driver default speed where the policy can't be set.
+func handToDriver(device Device, label string, fanIdx int) (how string, ok bool) {
+	ret := device.SetFanControlPolicy(fanIdx, nvml.FAN_POLICY_TEMPERATURE_CONTINOUS_SW)
+	if ret == nvml.SUCCESS {
+		return "AUTO fan policy", true
+	}
+	if ret != nvml.ERROR_NOT_SUPPORTED {
+		log.Printf("ERROR: Unable to set AUTO fan policy for %s Fan %d: %v", label, fanIdx, nvml.ErrorString(ret))
+	}
+	ret = device.SetDefaultFanSpeed(fanIdx)
+	if ret != nvml.SUCCESS {
+		log.Printf("ERROR: Unable to restore default fan speed for %s Fan %d: %v", label, fanIdx, nvml.ErrorString(ret))
+		return "", false
+	}
+	return "the driver default speed", true
+}
+
+// readFailed counts a failed update for device i (device may be nil when no
+// handle could be obtained) and applies the safe action once the limit is hit.
+func (c *fanController) readFailed(i int, device Device, reason string) {
+	c.readFailures[i]++
+	if !c.degraded[i] {
+		if c.readFailures[i] < c.failsafe.After {
+			return
+		}
+		c.degraded[i] = true
+		c.fanStop[i] = newFanStopState()
+		c.spinDown[i] = newSpinDownState()
+		log.Printf("ALERT: %s is DEGRADED after %d consecutive failed update(s) (last: %s): applying failsafe (%s) until readings recover.",
+			c.settings[i].label, c.readFailures[i], reason, c.failsafe)
+	}
+	if device == nil {
+		return
+	}
+
+	// Re-applied every update while degraded, in case an earlier attempt failed.
+	label := c.settings[i].label
+	for fanIdx := 0; fanIdx < c.fanCounts[i]; fanIdx++ {
+		if c.failsafe.Action == "speed" {
+			c.setManual(i, device, fanIdx, c.failsafe.Speed)
+		} else if _, ok := handToDriver(device, label, fanIdx); ok {
+			c.touched[i][fanIdx] = false
+		} else {
+			c.fanFailed(i, fmt.Sprintf("Fan %d AUTO handover", fanIdx))
+		}
+	}
+	if c.failsafe.Action == "speed" {
+		c.inAuto[i] = false
+		c.targets[i] = c.failsafe.Speed
+		c.rampGoals[i] = c.failsafe.Speed
+	} else {
+		c.inAuto[i] = true
+		c.rampGoals[i] = -1
+	}
+}
+
+// fanFailed records a failed fan read or command on device i. It counts
+// toward the read failsafe like a failed temperature read (see updateDone).
+func (c *fanController) fanFailed(i int, reason string) {
+	if c.fanFaults[i] == "" {
+		c.fanFaults[i] = reason
+	}
+}
+
+// readOK prepares device i for control after a good temperature read. A
+// degraded device resumes from the fans' real state, but stays degraded
+// until a whole update succeeds.
+func (c *fanController) readOK(i int, device Device, temp int) {
+	if !c.degraded[i] {
+		return
+	}
+	c.resync[i] = true
+	c.prevTemps[i] = temp
+	if c.failsafe.Action != "speed" {
+		// The driver owned the fans; continue from what they are doing now.
+		c.refreshFanSpeeds(i, device)
+	}
+}
+
+// updateDone ends an update of device i whose temperature was read: a fan
+// failure counts as a failed update, otherwise the failure count clears and
+// a degraded device recovers.
+func (c *fanController) updateDone(i int, device Device, temp int) {
+	if reason := c.fanFaults[i]; reason != "" {
+		c.readFailed(i, device, reason)
+		return
+	}
+	if c.readFailures[i] == 0 {
+		return
+	}
+	if c.degraded[i] {
+		log.Printf("INFO: %s readings recovered after %d failed update(s) (temp=%d°C): resuming normal control.",
+			c.settings[i].label, c.readFailures[i], temp)
+		c.degraded[i] = false
+	}
+	c.readFailures[i] = 0
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/NVIDIA/go-nvml/pkg/nvml"
+)
+
+// faultyBackend makes fan reads and commands fail while failing is set.
+type faultyBackend struct {
+	*simBackend
+	failing bool
+}
+
+type faultyDevice struct {
+	Device
+	b *faultyBackend
+}
+
+func (b *faultyBackend) DeviceByIndex(idx int) (Device, nvml.Return) {
+	dev, ret := b.simBackend.DeviceByIndex(idx)
+	if ret != nvml.SUCCESS {
+		return dev, ret
+	}
+	return faultyDevice{Device: dev, b: b}, ret
+}
+
+func (d faultyDevice) SetFanSpeed(fanIdx, speed int) nvml.Return {
+	if d.b.failing {
+		return nvml.ERROR_UNKNOWN
+	}
+	return d.Device.SetFanSpeed(fanIdx, speed)
+}
+
+func (d faultyDevice) FanSpeed(fanIdx int) (int, nvml.Return) {
+	if d.b.failing {
+		return 0, nvml.ERROR_GPU_IS_LOST
+	}
+	return d.Device.FanSpeed(fanIdx)
+}
+
+func TestReadFailsafeCountsFanFailures(t *testing.T) {
+	const config = `{
+		"time_to_update": 5,
+		"curve": true,
+		"ramp_up_per_second": 1,
+		"read_failsafe": {"after": 3},
+		"temperature_ranges": [
+			{"min_temperature": 0, "max_temperature": 40, "fan_speed": 30, "hysteresis": 3},
+			{"min_temperature": 40, "max_temperature": 200, "fan_speed": 80, "hysteresis": 3}
+		]
+	}`
+	tests := []struct {
+		name         string
+		failing      []bool // per update
+		wantDegraded bool
+		wantFailures int
+	}{
+		{"commands keep failing", []bool{true, true, true}, true, 3},
+		{"fewer failures than after", []bool{true, true}, false, 2},
+		{"a good update resets the count", []bool{true, true, false, true}, false, 1},
+		{"recovers once commands work again", []bool{true, true, true, false}, false, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := &faultyBackend{simBackend: newTestSim(t, 1, 1, []int{30})}
+			c := newTestController(t, config, b)
+			for n, failing := range tt.failing {
+				b.failing = failing
+				b.devices[0].SetTemperature(50 + 5*n) // above the floor, moving every update
+				c.tick()
+			}
+			if c.degraded[0] != tt.wantDegraded || c.readFailures[0] != tt.wantFailures {
+				t.Fatalf("degraded=%v failures=%d, want degraded=%v failures=%d",
+					c.degraded[0], c.readFailures[0], tt.wantDegraded, tt.wantFailures)
+			}
+			if tt.wantDegraded && b.devices[0].Policy(0) != nvml.FAN_POLICY_TEMPERATURE_CONTINOUS_SW {
+				t.Fatal("degraded device was not handed to AUTO")
+			}
+		})
+	}
+}
@@ -0,0 +1,153 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"math"
+
+	"github.com/NVIDIA/go-nvml/pkg/nvml"
+)
+
+// ---------- Device min/max fan speed ----------
+
+// fanLimits is the command range one fan accepts (DeviceGetMinMaxFanSpeed)
+// and how 1..100% targets are fitted into it.
+type fanLimits struct {
+	min, max int
+	mode     string // "clamp", "scale" or "off"
+}
+
+var noFanLimits = fanLimits{min: 0, max: 100, mode: "off"}
+
+// validFanSpeedRange checks a fan_speed_range value; "" means clamp.
+func validFanSpeedRange(mode string) error {
+	switch mode {
+	case "", "clamp", "scale", "off":
+		return nil
+	}
+	return fmt.Errorf("unknown fan_speed_range %q (expected clamp|scale|off)", mode)
+}
+
+// validateFanSpeedRanges checks every fan_speed_range setting in the config.
+func validateFanSpeedRanges(config Config) error {
+	if err := validFanSpeedRange(config.FanSpeedRange); err != nil {
+		return err
+	}
+	for key, dc := range config.Devices {
+		if dc.FanSpeedRange != nil {
+			if err := validFanSpeedRange(*dc.FanSpeedRange); err != nil {
+				return fmt.Errorf("devices[%q]: %w", key, err)
+			}
+		}
+	}
+	for _, hf := range config.HwmonFans {
+		if hf.FanSpeedRange != nil {
+			if err := validFanSpeedRange(*hf.FanSpeedRange); err != nil {
+				return fmt.Errorf("hwmon_fans[%q]: %w", hf.Name, err)
+			}
+		}
+	}
+	return nil
+}
+
+func (l fanLimits) String() string {
+	return fmt.Sprintf("%d..%d%% (%s)", l.min, l.max, l.mode)
+}
+
+// active reports whether commands are changed at all.
+func (l fanLimits) active() bool {
+	return l.mode != "off" && (l.min > 0 || l.max < 100)
+}
+
+// command maps a target onto what is sent to the fan. 0% stays 0%: a fan
+// that can't stop is handed to AUTO instead (see cantStop).
+func (l fanLimits) command(speed int) int {
+	if !l.active() || speed <= 0 {
+		return speed
+	}
+	if l.mode == "scale" {
+		return l.min + int(math.Round(float64(speed*(l.max-l.min))/100))
+	}
+	return clampInt(speed, l.min, l.max)
+}
+
+// cantStop reports whether a 0% target is below what the fan accepts.
+func (l fanLimits) cantStop(speed int) bool {
+	return speed <= 0 && l.mode != "off" && l.min > 0
+}
+
+// queryFanLimits reads dev's range for mode; without a usable one it returns
+// noFanLimits and the reason.
+func queryFanLimits(dev Device, mode string) (fanLimits, nvml.Return) {
+	if mode == "" {
+		mode = "clamp"
+	}
+	if mode == "off" {
+		return noFanLimits, nvml.SUCCESS
+	}
+	lo, hi, ret := dev.MinMaxFanSpeed()
+	if ret != nvml.SUCCESS {
+		return noFanLimits, ret
+	}
+	if lo < 0 || hi > 100 || lo >= hi {
+		return noFanLimits, nvml.ERROR_INVALID_ARGUMENT
+	}
+	return fanLimits{min: lo, max: hi, mode: mode}, nvml.SUCCESS
+}
+
+// resolveFanLimits queries the range of device i and logs it. Calibrated
+// fans keep 0..100%: their calibrated range already lies inside it.
+func (c *fanController) resolveFanLimits(i int, dev Device) []fanLimits {
+	s := c.settings[i]
+	out := make([]fanLimits, c.fanCounts[i])
+	for fanIdx := range out {
+		out[fanIdx] = noFanLimits
+	}
+	if dev == nil || len(out) == 0 {
+		return out
+	}
+	l, ret := queryFanLimits(dev, s.fanSpeedRange)
+	if ret != nvml.SUCCESS {
+		log.Printf("INFO: %s: min/max fan speed unavailable (%v); fan commands are not limited.", s.label, nvml.ErrorString(ret))
+		return out
+	}
+	if !l.active() {
+		return out
+	}
+	log.Printf("INFO: %s: fan speed range %s; 0%% targets hand the fans to AUTO.", s.label, l)
+	if s.fanStop != nil && l.min > 0 {
+		log.Printf("INFO: %s: fans can't stop (minimum %d%%); fan stop hands them to AUTO instead.", s.label, l.min)
+	}
+	for fanIdx := range out {
+		if calibratedFan(dev, fanIdx) {
+			log.Printf("INFO: %s Fan %d is calibrated; its calibrated range is used instead.", s.label, fanIdx)
+			continue
+		}
+		out[fanIdx] = l
+	}
+	return out
+}
+
+// stopBelowMinimum hands fan fanIdx of device i to AUTO in place of a 0%
+// command it can't run (see cantStop). Failures are logged and reported as false.
+func (c *fanController) stopBelowMinimum(i int, device Device, fanIdx int) bool {
+	label := c.settings[i].label
+	how, ok := handToDriver(device, label, fanIdx)
+	if !ok {
+		c.fanFailed(i, fmt.Sprintf("Fan %d AUTO handover", fanIdx))
+		return false
+	}
+	log.Printf("INFO: %s Fan %d: 0%% is below the device minimum (%d%%); handed it to %s.",
+		label, fanIdx, c.limits[i][fanIdx].min, how)
+	c.touched[i][fanIdx] = false
+	c.prevFanSpeeds[i][fanIdx] = 0
+	return true
+}
+
+// sentDetail is the update log part for a command the range changed, or "".
+func sentDetail(speed, sent int) string {
+	if sent == speed {
+		return ""
+	}
+	return fmt.Sprintf(", Sent=%d%%", sent)
+}
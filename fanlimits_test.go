@@ -0,0 +1,26 @@
+package main
+
+import "testing"
+
+func TestValidateFanSpeedRanges(t *testing.T) {
+	tests := []struct {
+		name    string
+		config  string
+		wantErr bool
+	}{
+		{"default", `{}`, false},
+		{"top level", `{"fan_speed_range": "scale"}`, false},
+		{"bad top level", `{"fan_speed_range": "fit"}`, true},
+		{"bad devices entry", `{"devices": {"0": {"fan_speed_range": "fit"}}}`, true},
+		{"hwmon entry", `{"hwmon_fans": [{"name": "case", "chip": "nct6798", "pwm": 1, "fan_speed_range": "off"}]}`, false},
+		{"bad hwmon entry", `{"hwmon_fans": [{"name": "case", "chip": "nct6798", "pwm": 1, "fan_speed_range": "fit"}]}`, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := loadTestConfig(t, tt.config)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("err=%v, want error %v", err, tt.wantErr)
+			}
+		})
+	}
+}
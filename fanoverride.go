@@ -0,0 +1,186 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+)
+
+// ---------- Per-fan overrides on multi-fan cards ----------
+
+// FanOverride adjusts what one fan index runs at relative to its device.
+// The device target is replaced by Curve (if set), then scaled, offset and
+// clamped. A 0% target stays 0%; the floor, fan stop and emergency speeds
+// are not adjusted. Sensor gives the fan its own temperature input.
+type FanOverride struct {
+	Sensor   string             `json:"sensor,omitempty"`    // own input (core|mem|hotspot or a composite); default: from the cooler target
+	Offset   int                `json:"offset,omitempty"`    // percentage points added
+	Scale    *float64           `json:"scale,omitempty"`     // multiplier applied before the offset (default 1)
+	MinSpeed *int               `json:"min_speed,omitempty"` // lower clamp (default 0)
+	MaxSpeed *int               `json:"max_speed,omitempty"` // upper clamp (default 100)
+	Curve    []CurvePointConfig `json:"curve,omitempty"`     // own points, evaluated at the device temperature
+}
+
+func (o FanOverride) validate() error {
+	if o.Offset < -100 || o.Offset > 100 {
+		return fmt.Errorf("offset must be -100..100 (got %d)", o.Offset)
+	}
+	if o.Scale != nil && *o.Scale <= 0 {
+		return fmt.Errorf("scale must be > 0 (got %g)", *o.Scale)
+	}
+	lo, hi := o.limits()
+	if lo < 0 || hi > 100 || lo > hi {
+		return fmt.Errorf("need 0 <= min_speed <= max_speed <= 100 (got %d..%d)", lo, hi)
+	}
+	if len(o.Curve) > 0 {
+		cc := CurveConfig{Floor: CurveFloorConfig{Temperature: o.Curve[0].Temp}, Points: o.Curve}
+		if err := cc.validate(); err != nil {
+			return fmt.Errorf("curve: %w", err)
+		}
+	}
+	return nil
+}
+
+func (o FanOverride) limits() (lo, hi int) {
+	lo, hi = 0, 100
+	if o.MinSpeed != nil {
+		lo = *o.MinSpeed
+	}
+	if o.MaxSpeed != nil {
+		hi = *o.MaxSpeed
+	}
+	return lo, hi
+}
+
+func (o FanOverride) String() string {
+	var parts []string
+	if o.Sensor != "" {
+		parts = append(parts, "sensor="+o.Sensor)
+	}
+	if len(o.Curve) > 0 {
+		parts = append(parts, fmt.Sprintf("own curve (%d point(s))", len(o.Curve)))
+	}
+	if o.Scale != nil {
+		parts = append(parts, fmt.Sprintf("scale=%g", *o.Scale))
+	}
+	if o.Offset != 0 {
+		parts = append(parts, fmt.Sprintf("offset=%+d%%", o.Offset))
+	}
+	if o.MinSpeed != nil || o.MaxSpeed != nil {
+		lo, hi := o.limits()
+		parts = append(parts, fmt.Sprintf("clamp=%d..%d%%", lo, hi))
+	}
+	if len(parts) == 0 {
+		return "no change"
+	}
+	return strings.Join(parts, ", ")
+}
+
+// validateFanOverrides checks every "fans" block in the config.
+func validateFanOverrides(config Config) error {
+	for key, dc := range config.Devices {
+		for fanIdx, o := range dc.Fans {
+			if fanIdx < 0 {
+				return fmt.Errorf("devices[%q].fans: invalid fan index %d", key, fanIdx)
+			}
+			if err := o.validate(); err != nil {
+				return fmt.Errorf("devices[%q].fans[%d]: %w", key, fanIdx, err)
+			}
+		}
+	}
+	for _, hf := range config.HwmonFans {
+		for fanIdx, o := range hf.Fans {
+			if fanIdx != 0 {
+				return fmt.Errorf("hwmon_fans[%q].fans: an hwmon fan only has fan 0 (got %d)", hf.Name, fanIdx)
+			}
+			if err := o.validate(); err != nil {
+				return fmt.Errorf("hwmon_fans[%q].fans[0]: %w", hf.Name, err)
+			}
+		}
+	}
+	return nil
+}
+
+// compileFanCurves builds the profiles of per-fan curves, using the
+// device's interpolation. Below a fan's first point it runs that point's speed.
+func (s *deviceSettings) compileFanCurves() {
+	s.fanCurves = nil
+	for fanIdx, o := range s.fans {
+		if len(o.Curve) == 0 {
+			continue
+		}
+		prof := CurveConfig{Floor: CurveFloorConfig{Temperature: o.Curve[0].Temp}, Points: o.Curve}.profile()
+		prof.floorSpeed = o.Curve[0].Speed
+		prof.setInterpolation(s.interpolation)
+		if s.fanCurves == nil {
+			s.fanCurves = map[int]curveProfile{}
+		}
+		s.fanCurves[fanIdx] = prof
+	}
+}
+
+// fanTarget applies fan fanIdx's override to the device target at temp.
+func (s deviceSettings) fanTarget(fanIdx, target, temp int) int {
+	o, ok := s.fans[fanIdx]
+	if !ok || target <= 0 {
+		return target
+	}
+	if prof, ok := s.fanCurves[fanIdx]; ok {
+		target, _ = curveSpeedForTempWithProfile(temp, prof)
+	}
+	v := float64(target)
+	if o.Scale != nil {
+		v *= *o.Scale
+	}
+	lo, hi := o.limits()
+	return clampInt(int(math.Round(v))+o.Offset, lo, hi)
+}
+
+// fanGroup is a set of fans that were set to the same speed in one update
+// (and sent the same command), with the target each of them is ramping toward.
+type fanGroup struct {
+	speed   int
+	sent    int
+	fans    []int
+	targets []int
+}
+
+// groupFans collects fanIdx under the speed it was set to, keeping first-seen order.
+func groupFans(groups []fanGroup, fanIdx, speed, sent, target int) []fanGroup {
+	for g := range groups {
+		if groups[g].speed == speed && groups[g].sent == sent {
+			groups[g].fans = append(groups[g].fans, fanIdx)
+			groups[g].targets = append(groups[g].targets, target)
+			return groups
+		}
+	}
+	return append(groups, fanGroup{speed: speed, sent: sent, fans: []int{fanIdx}, targets: []int{target}})
+}
+
+// rampDetail describes the targets of a group still ramping, or "" if none is.
+func (g fanGroup) rampDetail() string {
+	ramping, same := false, true
+	for _, t := range g.targets {
+		ramping = ramping || t != g.speed
+		same = same && t == g.targets[0]
+	}
+	switch {
+	case !ramping:
+		return ""
+	case same:
+		return fmt.Sprintf(", Target=%d%% (ramping)", g.targets[0])
+	default:
+		return fmt.Sprintf(", Targets=[%s]%% (ramping)", formatFanList(g.targets))
+	}
+}
+
+// sortedFanOverrides returns the override fan indices in order, for logging.
+func sortedFanOverrides(fans map[int]FanOverride) []int {
+	out := make([]int, 0, len(fans))
+	for fanIdx := range fans {
+		out = append(out, fanIdx)
+	}
+	sort.Ints(out)
+	return out
+}
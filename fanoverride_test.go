@@ -0,0 +1,102 @@
+package main
+
+import "testing"
+
+func TestFanTarget(t *testing.T) {
+	half, double := 0.5, 2.0
+	lo, hi := intPtr(35), intPtr(70)
+	s := deviceSettings{fans: map[int]FanOverride{
+		1: {Offset: 10},
+		2: {Scale: &half, MinSpeed: lo},
+		3: {Scale: &double, Offset: -5, MaxSpeed: hi},
+		4: {Curve: []CurvePointConfig{{Temp: 50, Speed: 30}, {Temp: 80, Speed: 70}}},
+		5: {Curve: []CurvePointConfig{{Temp: 50, Speed: 30}, {Temp: 80, Speed: 70}}, Offset: 5},
+	}}
+	s.compileFanCurves()
+	tests := []struct {
+		name         string
+		fan          int
+		target, temp int
+		want         int
+	}{
+		{"no override", 0, 60, 65, 60},
+		{"offset", 1, 60, 65, 70},
+		{"offset clamps at 100", 1, 95, 65, 100},
+		{"scale clamps at min_speed", 2, 60, 65, 35},
+		{"scale above min_speed", 2, 90, 65, 45},
+		{"scale and offset clamp at max_speed", 3, 60, 65, 70},
+		{"scale then offset", 3, 30, 65, 55},
+		{"own curve", 4, 60, 65, 50},
+		{"own curve below its first point", 4, 60, 40, 30},
+		{"own curve above its last point", 4, 60, 90, 70},
+		{"own curve then offset", 5, 60, 65, 55},
+		{"0% stays 0%", 1, 0, 65, 0},
+		{"0% stays 0% with a curve", 4, 0, 65, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := s.fanTarget(tt.fan, tt.target, tt.temp); got != tt.want {
+				t.Errorf("fanTarget(%d, %d, %d)=%d, want %d", tt.fan, tt.target, tt.temp, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGroupFans(t *testing.T) {
+	var groups []fanGroup
+	groups = groupFans(groups, 0, 70, 70, 70)
+	groups = groupFans(groups, 1, 56, 56, 56)
+	groups = groupFans(groups, 2, 70, 70, 80)
+	groups = groupFans(groups, 3, 70, 75, 70) // same speed, different command
+	if len(groups) != 3 {
+		t.Fatalf("%d group(s), want 3: %+v", len(groups), groups)
+	}
+	want := []struct {
+		speed, sent int
+		fans        string
+		detail      string
+	}{
+		{70, 70, "0,2", ", Targets=[70,80]% (ramping)"},
+		{56, 56, "1", ""},
+		{70, 75, "3", ""},
+	}
+	for g, w := range want {
+		got := groups[g]
+		if got.speed != w.speed || got.sent != w.sent || formatFanList(got.fans) != w.fans {
+			t.Errorf("group %d: speed %d sent %d fans [%s]; want %d, %d, [%s]",
+				g, got.speed, got.sent, formatFanList(got.fans), w.speed, w.sent, w.fans)
+		}
+		if d := got.rampDetail(); d != w.detail {
+			t.Errorf("group %d: rampDetail %q, want %q", g, d, w.detail)
+		}
+	}
+	same := groupFans(groupFans(nil, 0, 40, 40, 60), 1, 40, 40, 60)
+	if d := same[0].rampDetail(); d != ", Target=60% (ramping)" {
+		t.Errorf("rampDetail %q for a shared target", d)
+	}
+}
+
+// TestPerFanTargetsInControlLoop drives a three-fan card whose fans have
+// different overrides and checks each fan gets its own speed.
+func TestPerFanTargetsInControlLoop(t *testing.T) {
+	sim := newTestSim(t, 1, 3, []int{40})
+	c := newTestController(t, `{
+		"time_to_update": 5,
+		"temperature_ranges": [{"min_temperature": 0, "max_temperature": 200, "fan_speed": 60, "hysteresis": 2}],
+		"devices": {"0": {
+			"temperature_ranges": [{"min_temperature": 0, "max_temperature": 200, "fan_speed": 60, "hysteresis": 2}],
+			"fans": {
+				"0": {"curve": [{"temp": 50, "speed": 30}, {"temp": 80, "speed": 70}]},
+				"1": {"offset": 10},
+				"2": {"scale": 0.5, "min_speed": 35}
+			}
+		}}
+	}`, sim)
+	sim.devices[0].SetTemperature(65)
+	c.tick()
+	for fanIdx, want := range []int{50, 70, 35} {
+		if got, _ := sim.devices[0].FanSpeed(fanIdx); got != want {
+			t.Errorf("fan %d at %d%%, want %d%%", fanIdx, got, want)
+		}
+	}
+}
@@ -0,0 +1,156 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"math"
+
+	"github.com/NVIDIA/go-nvml/pkg/nvml"
+)
+
+// ---------- Zero-RPM fan stop ----------
+
+// FanStopConfig stops the fans below a temperature instead of running the
+// normal control mode there.
+type FanStopConfig struct {
+	Temperature   int  `json:"temperature"`               // fans stop below this temperature
+	Hysteresis    int  `json:"hysteresis,omitempty"`      // restart at temperature + hysteresis
+	KickSpeed     int  `json:"kick_speed,omitempty"`      // speed applied briefly on restart (default 50)
+	KickSeconds   *int `json:"kick_seconds,omitempty"`    // how long the kick is held (default 2, at least one update; 0 = no kick)
+	MinOnSeconds  int  `json:"min_on_seconds,omitempty"`  // fans run at least this long before stopping again
+	MinOffSeconds int  `json:"min_off_seconds,omitempty"` // fans stay stopped at least this long
+}
+
+const (
+	defaultKickSpeed   = 50
+	defaultKickSeconds = 2
+)
+
+func (f *FanStopConfig) validate() error {
+	if f.KickSpeed == 0 {
+		f.KickSpeed = defaultKickSpeed
+	}
+	if f.KickSeconds == nil {
+		kick := defaultKickSeconds
+		f.KickSeconds = &kick
+	}
+	if f.KickSpeed < 1 || f.KickSpeed > 100 {
+		return fmt.Errorf("kick_speed must be 1..100 (got %d)", f.KickSpeed)
+	}
+	if f.Hysteresis < 0 || *f.KickSeconds < 0 || f.MinOnSeconds < 0 || f.MinOffSeconds < 0 {
+		return fmt.Errorf("hysteresis and durations must be >= 0")
+	}
+	return nil
+}
+
+func (f FanStopConfig) String() string {
+	kick := "without a kick"
+	if *f.KickSeconds > 0 {
+		kick = fmt.Sprintf("with %d%% for %ds", f.KickSpeed, *f.KickSeconds)
+	}
+	return fmt.Sprintf("stop below %d°C, restart at %d°C %s, min on %ds / off %ds",
+		f.Temperature, f.Temperature+f.Hysteresis, kick, f.MinOnSeconds, f.MinOffSeconds)
+}
+
+// fanStopState is the per-device stop/start timing, on the controller clock.
+type fanStopState struct {
+	stopped   bool
+	since     float64 // last stop or restart
+	kickUntil float64
+}
+
+func newFanStopState() fanStopState {
+	return fanStopState{since: math.Inf(-1), kickUntil: math.Inf(-1)}
+}
+
+// applyFanStop handles the stopped and kick phases for device i. It returns
+// true when it owns the fans this update and the normal mode must be skipped.
+func (c *fanController) applyFanStop(i int, device Device, temp int) bool {
+	cfg := c.settings[i].fanStop
+	if cfg == nil {
+		return false
+	}
+	st := &c.fanStop[i]
+	label := c.settings[i].label
+
+	switch {
+	case st.stopped:
+		hot := temp >= cfg.Temperature+cfg.Hysteresis
+		if !hot && !c.feedForwardDemand(i) || c.now-st.since < float64(cfg.MinOffSeconds) {
+			break
+		}
+		reason := fmt.Sprintf("temp=%s reached %d°C", c.tempString(i, temp), cfg.Temperature+cfg.Hysteresis)
+		if !hot {
+			reason = fmt.Sprintf("feed-forward +%d%% at temp=%s", c.feedForward[i].term, c.tempString(i, temp))
+		}
+		st.stopped, st.since = false, c.now
+		st.kickUntil = c.now + float64(*cfg.KickSeconds)
+		if *cfg.KickSeconds == 0 {
+			// No kick: the normal mode takes over from the stopped fans right away.
+			log.Printf("INFO: %s fan stop: %s, restarting fans.", label, reason)
+			c.inAuto[i] = false
+			c.rampGoals[i] = 0
+			c.resync[i] = true
+			return false
+		}
+		log.Printf("INFO: %s fan stop: %s, restarting fans with a %d%% kick for %ds.",
+			label, reason, cfg.KickSpeed, *cfg.KickSeconds)
+		// Like the emergency, the kick is not capped by a schedule: a capped kick may not start the fans.
+		for fanIdx := 0; fanIdx < c.fanCounts[i]; fanIdx++ {
+			c.setManual(i, device, fanIdx, cfg.KickSpeed)
+		}
+		c.targets[i] = cfg.KickSpeed
+
+	case c.now < st.kickUntil:
+		// Hold the kick speed until the fans are reliably spinning.
+
+	case st.kickUntil > st.since && c.now >= st.kickUntil:
+		// Kick finished: hand over to the normal mode from the kick speed.
+		st.kickUntil = st.since
+		c.inAuto[i] = false
+		c.rampGoals[i] = cfg.KickSpeed
+		c.resync[i] = true
+		return false
+
+	case temp < cfg.Temperature && c.feedForward[i].term == 0 && c.now-st.since >= float64(cfg.MinOnSeconds):
+		st.stopped, st.since = true, c.now
+		log.Printf("INFO: %s fan stop: temp=%s below %d°C, stopping fans.", label, c.tempString(i, temp), cfg.Temperature)
+		c.stopFans(i, device)
+		c.targets[i] = 0
+		c.spinDown[i] = newSpinDownState()
+
+	default:
+		return false
+	}
+
+	c.pid[i].reset()
+	c.prevTemps[i] = temp
+	return true
+}
+
+// stopFans sets every fan of device i to 0%, handing fans that can't stop
+// or reject 0% to the driver instead.
+func (c *fanController) stopFans(i int, device Device) {
+	label := c.settings[i].label
+	for fanIdx := 0; fanIdx < c.fanCounts[i]; fanIdx++ {
+		if c.limits[i][fanIdx].cantStop(0) {
+			c.stopBelowMinimum(i, device, fanIdx)
+			continue
+		}
+		ret := device.SetFanControlPolicy(fanIdx, nvml.FAN_POLICY_MANUAL)
+		if ret == nvml.SUCCESS || ret == nvml.ERROR_NOT_SUPPORTED {
+			c.touched[i][fanIdx] = true
+			ret = device.SetFanSpeed(fanIdx, 0)
+			if ret == nvml.SUCCESS {
+				c.prevFanSpeeds[i][fanIdx] = 0
+				continue
+			}
+		}
+		log.Printf("WARN: %s Fan %d rejected 0%% (%v); handing it to AUTO instead.", label, fanIdx, nvml.ErrorString(ret))
+		if _, ok := handToDriver(device, label, fanIdx); ok {
+			c.touched[i][fanIdx] = false
+		} else {
+			c.fanFailed(i, fmt.Sprintf("Fan %d AUTO handover", fanIdx))
+		}
+	}
+}
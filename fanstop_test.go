@@ -0,0 +1,52 @@
+package main
+
+import "testing"
+
+func TestFanStopKick(t *testing.T) {
+	tests := []struct {
+		name     string
+		fanStop  string
+		schedule string
+		want     []int // fan speed after each update: stop, restart, next
+	}{
+		{
+			name:    "default kick",
+			fanStop: `{"temperature": 45, "hysteresis": 3}`,
+			want:    []int{0, 50, 50},
+		},
+		{
+			name:    "kick_seconds 0 restarts without a kick",
+			fanStop: `{"temperature": 45, "hysteresis": 3, "kick_seconds": 0}`,
+			want:    []int{0, 40, 40},
+		},
+		{
+			name:     "a schedule cap does not lower the kick",
+			fanStop:  `{"temperature": 45, "hysteresis": 3, "kick_speed": 70}`,
+			schedule: `[{"name": "quiet", "start": "00:00", "end": "00:00", "max_speed": 35}]`,
+			want:     []int{0, 70, 70},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			schedules := tt.schedule
+			if schedules == "" {
+				schedules = "[]"
+			}
+			sim := newTestSim(t, 1, 1, []int{50})
+			sim.setFanSpeedRange(0, 100)
+			c := newTestController(t, `{
+				"time_to_update": 1,
+				"fan_stop": `+tt.fanStop+`,
+				"schedules": `+schedules+`,
+				"temperature_ranges": [{"min_temperature": 0, "max_temperature": 200, "fan_speed": 40, "hysteresis": 2}]
+			}`, sim)
+			for n, temp := range []int{40, 50, 52} {
+				sim.devices[0].SetTemperature(temp)
+				c.tick()
+				if got, _ := sim.devices[0].FanSpeed(0); got != tt.want[n] {
+					t.Fatalf("update %d (%d°C): fan at %d%%, want %d%%", n, temp, got, tt.want[n])
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,183 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"math"
+	"strings"
+
+	"github.com/NVIDIA/go-nvml/pkg/nvml"
+)
+
+// ---------- Power / utilization feed-forward ----------
+
+// FeedForwardConfig raises the fan target from load before the temperature
+// catches up. The term is combined with the temperature-based target.
+type FeedForwardConfig struct {
+	IdleWatts      float64 `json:"idle_watts,omitempty"`              // power that adds nothing
+	PercentPerWatt float64 `json:"percent_per_watt,omitempty"`        // extra fan % per watt above idle_watts
+	IdleUtil       int     `json:"idle_utilization,omitempty"`        // GPU utilization % that adds nothing
+	PercentPerUtil float64 `json:"percent_per_utilization,omitempty"` // extra fan % per utilization point above idle_utilization
+	MaxPercent     int     `json:"max_percent,omitempty"`             // cap on the term (default 100)
+	Combine        string  `json:"combine,omitempty"`                 // "max" (default) or "sum"
+	Hysteresis     int     `json:"hysteresis,omitempty"`              // term change that re-applies the curve, and term that leaves AUTO or fan stop (default 5)
+}
+
+const defaultFeedForwardHysteresis = 5
+
+func (f *FeedForwardConfig) validate() error {
+	if f.Combine == "" {
+		f.Combine = "max"
+	}
+	if f.MaxPercent == 0 {
+		f.MaxPercent = 100
+	}
+	if f.Hysteresis == 0 {
+		f.Hysteresis = defaultFeedForwardHysteresis
+	}
+	if f.Combine != "max" && f.Combine != "sum" {
+		return fmt.Errorf("unknown combine %q (expected max|sum)", f.Combine)
+	}
+	if f.PercentPerWatt < 0 || f.PercentPerUtil < 0 || f.IdleWatts < 0 {
+		return fmt.Errorf("idle_watts and the per-watt/per-utilization rates must be >= 0")
+	}
+	if f.PercentPerWatt == 0 && f.PercentPerUtil == 0 {
+		return fmt.Errorf("set percent_per_watt and/or percent_per_utilization")
+	}
+	if f.IdleUtil < 0 || f.IdleUtil > 100 {
+		return fmt.Errorf("idle_utilization must be 0..100 (got %d)", f.IdleUtil)
+	}
+	if f.MaxPercent < 1 || f.MaxPercent > 100 {
+		return fmt.Errorf("max_percent must be 1..100 (got %d)", f.MaxPercent)
+	}
+	if f.Hysteresis < 0 {
+		return fmt.Errorf("hysteresis must be >= 0 (got %d)", f.Hysteresis)
+	}
+	return nil
+}
+
+func (f FeedForwardConfig) String() string {
+	var parts []string
+	if f.PercentPerWatt > 0 {
+		parts = append(parts, fmt.Sprintf("%g%%/W above %gW", f.PercentPerWatt, f.IdleWatts))
+	}
+	if f.PercentPerUtil > 0 {
+		parts = append(parts, fmt.Sprintf("%g%%/util%% above %d%%", f.PercentPerUtil, f.IdleUtil))
+	}
+	return fmt.Sprintf("%s, up to %d%%, combined by %s", strings.Join(parts, " + "), f.MaxPercent, f.Combine)
+}
+
+// feedForwardState is the last reading and term per device.
+type feedForwardState struct {
+	watts   float64 // -1 = not read
+	util    int     // -1 = not read
+	term    int     // current feed-forward percent
+	applied int     // term the current curve goal was computed with
+	base    int     // step mode: temperature-only target (also kept for spin-down)
+	warned  bool
+}
+
+func newFeedForwardState() feedForwardState {
+	return feedForwardState{watts: -1, util: -1}
+}
+
+// updateFeedForward reads the load inputs of device i and recomputes its
+// term. A failed reading counts as idle and is logged once.
+func (c *fanController) updateFeedForward(i int, device Device) {
+	cfg := c.settings[i].feedForward
+	if cfg == nil {
+		return
+	}
+	st := &c.feedForward[i]
+	st.watts, st.util = -1, -1
+	var fails []string
+	term := 0.0
+	if cfg.PercentPerWatt > 0 {
+		if mw, ret := device.PowerUsage(); ret == nvml.SUCCESS {
+			st.watts = float64(mw) / 1000
+			term += cfg.PercentPerWatt * math.Max(0, st.watts-cfg.IdleWatts)
+		} else {
+			fails = append(fails, "power: "+nvml.ErrorString(ret))
+		}
+	}
+	if cfg.PercentPerUtil > 0 {
+		if gpu, _, ret := device.Utilization(); ret == nvml.SUCCESS {
+			st.util = gpu
+			term += cfg.PercentPerUtil * float64(clampInt(gpu-cfg.IdleUtil, 0, 100))
+		} else {
+			fails = append(fails, "utilization: "+nvml.ErrorString(ret))
+		}
+	}
+	if len(fails) > 0 && !st.warned {
+		log.Printf("WARN: %s feed-forward input unavailable (%s); treating it as idle.", c.settings[i].label, strings.Join(fails, ", "))
+		st.warned = true
+	}
+	st.term = clampInt(int(math.Round(term)), 0, cfg.MaxPercent)
+}
+
+// combineFeedForward merges the temperature-based target with the term.
+func (c *fanController) combineFeedForward(i, target int) int {
+	cfg := c.settings[i].feedForward
+	if cfg == nil {
+		return target
+	}
+	term := c.feedForward[i].term
+	if cfg.Combine == "sum" {
+		return clampInt(target+term, 0, 100)
+	}
+	if term > target {
+		return term
+	}
+	return target
+}
+
+// feedForwardChanged reports whether the term moved enough since the current
+// goal was set to re-apply the curve despite the temperature hysteresis.
+func (c *fanController) feedForwardChanged(i int) bool {
+	cfg := c.settings[i].feedForward
+	if cfg == nil {
+		return false
+	}
+	st := c.feedForward[i]
+	return abs(st.term-st.applied) >= cfg.Hysteresis && st.term != st.applied
+}
+
+// feedForwardDemand reports whether the term of device i is large enough to
+// run the fans on its own below the floor or while fan stop holds them: at
+// least its hysteresis (and at least 1%). They go back once the term is 0.
+func (c *fanController) feedForwardDemand(i int) bool {
+	cfg := c.settings[i].feedForward
+	if cfg == nil {
+		return false
+	}
+	need := cfg.Hysteresis
+	if need < 1 {
+		need = 1
+	}
+	return c.feedForward[i].term >= need
+}
+
+// feedForwardDetail is the inputs part of the update log line, or "".
+func (c *fanController) feedForwardDetail(i, base int) string {
+	cfg := c.settings[i].feedForward
+	if cfg == nil {
+		return ""
+	}
+	st := c.feedForward[i]
+	var inputs []string
+	if cfg.PercentPerWatt > 0 {
+		if st.watts < 0 {
+			inputs = append(inputs, "Power=n/a")
+		} else {
+			inputs = append(inputs, fmt.Sprintf("Power=%.0fW", st.watts))
+		}
+	}
+	if cfg.PercentPerUtil > 0 {
+		if st.util < 0 {
+			inputs = append(inputs, "Util=n/a")
+		} else {
+			inputs = append(inputs, fmt.Sprintf("Util=%d%%", st.util))
+		}
+	}
+	return fmt.Sprintf(", %s, TempTarget=%d%%, FF=+%d%% (%s)", strings.Join(inputs, ", "), base, st.term, cfg.Combine)
+}
@@ -0,0 +1,125 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/NVIDIA/go-nvml/pkg/nvml"
+)
+
+func TestFeedForwardTerm(t *testing.T) {
+	tests := []struct {
+		name        string
+		feedForward string
+		watts       float64
+		util        int
+		target      int // temperature target passed to combineFeedForward
+		wantTerm    int
+		wantSpeed   int
+	}{
+		{"idle power", `{"idle_watts": 50, "percent_per_watt": 0.3}`, 30, 0, 40, 0, 40},
+		{"power above idle", `{"idle_watts": 50, "percent_per_watt": 0.3}`, 300, 0, 40, 75, 75},
+		{"max keeps a higher temperature target", `{"idle_watts": 50, "percent_per_watt": 0.3}`, 100, 0, 40, 15, 40},
+		{"sum", `{"idle_watts": 50, "percent_per_watt": 0.3, "combine": "sum"}`, 100, 0, 40, 15, 55},
+		{"sum clamps at 100", `{"idle_watts": 50, "percent_per_watt": 0.3, "combine": "sum"}`, 300, 0, 40, 75, 100},
+		{"max_percent", `{"idle_watts": 50, "percent_per_watt": 0.3, "max_percent": 60}`, 300, 0, 40, 60, 60},
+		{"utilization", `{"idle_utilization": 20, "percent_per_utilization": 0.5}`, 0, 90, 20, 35, 35},
+		{"power and utilization add up", `{"idle_watts": 50, "percent_per_watt": 0.1, "idle_utilization": 20, "percent_per_utilization": 0.5}`, 150, 90, 20, 45, 45},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sim := newTestSim(t, 1, 1, []int{40})
+			c := newTestController(t, `{
+				"feed_forward": `+tt.feedForward+`,
+				"temperature_ranges": [{"min_temperature": 0, "max_temperature": 200, "fan_speed": 40, "hysteresis": 2}]
+			}`, sim)
+			sim.devices[0].SetPower(tt.watts)
+			sim.devices[0].SetUtilization(tt.util)
+			c.updateFeedForward(0, sim.devices[0])
+			if got := c.feedForward[0].term; got != tt.wantTerm {
+				t.Errorf("term %d%%, want %d%%", got, tt.wantTerm)
+			}
+			if got := c.combineFeedForward(0, tt.target); got != tt.wantSpeed {
+				t.Errorf("combined with %d%%: %d%%, want %d%%", tt.target, got, tt.wantSpeed)
+			}
+		})
+	}
+}
+
+// TestFeedForwardLeavesAuto steps the power below the curve floor: a term of
+// at least its hysteresis takes the fans out of AUTO, and a term of 0 hands
+// them back.
+func TestFeedForwardLeavesAuto(t *testing.T) {
+	sim := newTestSim(t, 1, 1, []int{40})
+	sim.setFanSpeedRange(0, 100)
+	c := newTestController(t, `{
+		"time_to_update": 1,
+		"feed_forward": {"idle_watts": 50, "percent_per_watt": 0.3},
+		"curve_points": {
+			"floor": {"temperature": 45},
+			"points": [{"temp": 55, "speed": 40}, {"temp": 80, "speed": 100}]
+		}
+	}`, sim)
+	steps := []struct {
+		watts    float64
+		wantAuto bool
+		want     int // fan speed while in MANUAL
+	}{
+		{30, true, 0},
+		{60, true, 0}, // term 3% is below the feed-forward hysteresis
+		{300, false, 75},
+		{200, false, 45},
+		{60, false, 3},
+		{30, true, 0},
+	}
+	for n, s := range steps {
+		sim.devices[0].SetPower(s.watts)
+		c.tick()
+		if c.inAuto[0] != s.wantAuto {
+			t.Fatalf("update %d (%gW): in AUTO %v, want %v", n, s.watts, c.inAuto[0], s.wantAuto)
+		}
+		if s.wantAuto {
+			if p := sim.devices[0].Policy(0); p != nvml.FAN_POLICY_TEMPERATURE_CONTINOUS_SW {
+				t.Fatalf("update %d (%gW): fan policy %v, want AUTO", n, s.watts, p)
+			}
+			continue
+		}
+		if got, _ := sim.devices[0].FanSpeed(0); got != s.want {
+			t.Fatalf("update %d (%gW): fan at %d%%, want %d%%", n, s.watts, got, s.want)
+		}
+	}
+}
+
+// TestFeedForwardRestartsStoppedFans steps the power while fan stop holds
+// the fans: the term restarts them below the restart temperature, and they
+// stop again once it is back to 0.
+func TestFeedForwardRestartsStoppedFans(t *testing.T) {
+	sim := newTestSim(t, 1, 1, []int{40})
+	sim.setFanSpeedRange(0, 100)
+	c := newTestController(t, `{
+		"time_to_update": 1,
+		"feed_forward": {"idle_watts": 50, "percent_per_watt": 0.3},
+		"fan_stop": {"temperature": 45, "hysteresis": 3, "kick_seconds": 0},
+		"temperature_ranges": [{"min_temperature": 0, "max_temperature": 200, "fan_speed": 40, "hysteresis": 2}]
+	}`, sim)
+	steps := []struct {
+		watts       float64
+		wantStopped bool
+		want        int
+	}{
+		{30, true, 0},
+		{60, true, 0}, // term 3% is below the feed-forward hysteresis
+		{300, false, 75},
+		{60, false, 40},
+		{30, true, 0},
+	}
+	for n, s := range steps {
+		sim.devices[0].SetPower(s.watts)
+		c.tick()
+		if c.fanStop[0].stopped != s.wantStopped {
+			t.Fatalf("update %d (%gW): stopped %v, want %v", n, s.watts, c.fanStop[0].stopped, s.wantStopped)
+		}
+		if got, _ := sim.devices[0].FanSpeed(0); got != s.want {
+			t.Fatalf("update %d (%gW): fan at %d%%, want %d%%", n, s.watts, got, s.want)
+		}
+	}
+}
@@ -0,0 +1,164 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"sort"
+)
+
+// ---------- Temperature input filtering ----------
+
+// FilterConfig smooths raw temperature samples before the control decision
+// and drops readings that cannot be real.
+type FilterConfig struct {
+	Type     string  `json:"type"`                // "ema", "median", "max" or "none"
+	Alpha    float64 `json:"alpha,omitempty"`     // ema: weight of the newest sample, (0,1]
+	Window   int     `json:"window,omitempty"`    // median/max: number of samples
+	MaxJump  int     `json:"max_jump,omitempty"`  // reject a change larger than this per update (0 = off)
+	MinValid *int    `json:"min_valid,omitempty"` // lowest plausible reading (default 1)
+	MaxValid *int    `json:"max_valid,omitempty"` // highest plausible reading (default 254)
+}
+
+const (
+	defaultMinValidTemp = 1   // NVML reports 0°C from a GPU that fell off the bus
+	defaultMaxValidTemp = 254 // and 255°C from a sensor that failed
+)
+
+// tempFilter is the per-device filter state.
+type tempFilter struct {
+	kind     string
+	alpha    float64
+	window   int
+	maxJump  int
+	minValid int
+	maxValid int
+
+	samples []int   // accepted samples, newest last (median/max)
+	ema     float64 // running average (ema)
+	last    int     // last accepted sample
+	pending *int    // rejected jump waiting for confirmation
+	primed  bool
+}
+
+// newTempFilter validates cfg; a nil cfg only applies the default plausibility range.
+func newTempFilter(cfg *FilterConfig) (*tempFilter, error) {
+	f := &tempFilter{kind: "none", minValid: defaultMinValidTemp, maxValid: defaultMaxValidTemp}
+	if cfg == nil {
+		return f, nil
+	}
+	if cfg.MinValid != nil {
+		f.minValid = *cfg.MinValid
+	}
+	if cfg.MaxValid != nil {
+		f.maxValid = *cfg.MaxValid
+	}
+	if f.minValid > f.maxValid {
+		return nil, fmt.Errorf("min_valid (%d) must not exceed max_valid (%d)", f.minValid, f.maxValid)
+	}
+	if cfg.MaxJump < 0 {
+		return nil, fmt.Errorf("max_jump must be >= 0 (got %d)", cfg.MaxJump)
+	}
+	f.maxJump = cfg.MaxJump
+
+	switch cfg.Type {
+	case "", "none":
+	case "ema":
+		if cfg.Alpha <= 0 || cfg.Alpha > 1 {
+			return nil, fmt.Errorf("ema alpha must be in (0,1] (got %g)", cfg.Alpha)
+		}
+		f.kind, f.alpha = "ema", cfg.Alpha
+	case "median", "max":
+		if cfg.Window < 1 {
+			return nil, fmt.Errorf("%s window must be >= 1 (got %d)", cfg.Type, cfg.Window)
+		}
+		f.kind, f.window = cfg.Type, cfg.Window
+	default:
+		return nil, fmt.Errorf("unknown filter type %q (expected ema|median|max|none)", cfg.Type)
+	}
+	return f, nil
+}
+
+func (f *tempFilter) String() string {
+	out := f.kind
+	switch f.kind {
+	case "ema":
+		out += fmt.Sprintf(" alpha=%g", f.alpha)
+	case "median", "max":
+		out += fmt.Sprintf(" window=%d", f.window)
+	}
+	if f.maxJump > 0 {
+		out += fmt.Sprintf(", max_jump=%d°C", f.maxJump)
+	}
+	return out + fmt.Sprintf(", valid=%d..%d°C", f.minValid, f.maxValid)
+}
+
+// active reports whether the filter can change a plausible sample.
+func (f *tempFilter) active() bool {
+	return f.kind != "none"
+}
+
+// update feeds one raw sample. It returns the filtered temperature, or
+// ok=false with a reason when the sample is dropped as implausible.
+// A jump larger than max_jump is accepted once the next sample confirms it:
+// it lies within max_jump of the held one, or moved on in the same direction.
+func (f *tempFilter) update(raw int) (filtered int, ok bool, reason string) {
+	if raw < f.minValid || raw > f.maxValid {
+		return 0, false, fmt.Sprintf("outside %d..%d°C", f.minValid, f.maxValid)
+	}
+	if f.primed && f.maxJump > 0 && abs(raw-f.last) > f.maxJump && !f.confirms(raw) {
+		v := raw
+		f.pending = &v
+		return 0, false, fmt.Sprintf("jump of %+d°C exceeds max_jump=%d°C", raw-f.last, f.maxJump)
+	}
+	f.pending = nil
+	f.last = raw
+
+	switch f.kind {
+	case "ema":
+		if !f.primed {
+			f.ema = float64(raw)
+		} else {
+			f.ema = f.alpha*float64(raw) + (1-f.alpha)*f.ema
+		}
+		filtered = int(math.Round(f.ema))
+	case "median", "max":
+		f.samples = append(f.samples, raw)
+		if len(f.samples) > f.window {
+			f.samples = f.samples[len(f.samples)-f.window:]
+		}
+		filtered = f.reduce()
+	default:
+		filtered = raw
+	}
+	f.primed = true
+	return filtered, true, ""
+}
+
+// confirms reports whether raw backs up the pending jump. A GPU heating faster
+// than max_jump per update keeps moving away from it, which is just as real.
+func (f *tempFilter) confirms(raw int) bool {
+	if f.pending == nil {
+		return false
+	}
+	p := *f.pending
+	return abs(raw-p) <= f.maxJump || (raw-p)*(p-f.last) > 0
+}
+
+func (f *tempFilter) reduce() int {
+	if f.kind == "max" {
+		m := f.samples[0]
+		for _, v := range f.samples[1:] {
+			if v > m {
+				m = v
+			}
+		}
+		return m
+	}
+	sorted := append([]int(nil), f.samples...)
+	sort.Ints(sorted)
+	n := len(sorted)
+	if n%2 == 1 {
+		return sorted[n/2]
+	}
+	return int(math.Round(float64(sorted[n/2-1]+sorted[n/2]) / 2))
+}
@@ -0,0 +1,138 @@
+package main
+
+import "testing"
+
+func intPtr(v int) *int { return &v }
+
+func TestTempFilterUpdate(t *testing.T) {
+	type step struct {
+		raw      int
+		want     int
+		accepted bool
+	}
+	tests := []struct {
+		name  string
+		cfg   *FilterConfig
+		steps []step
+	}{
+		{
+			name:  "default range drops lost and failed sensors",
+			cfg:   nil,
+			steps: []step{{50, 50, true}, {0, 0, false}, {255, 0, false}, {51, 51, true}},
+		},
+		{
+			name:  "custom valid range",
+			cfg:   &FilterConfig{MinValid: intPtr(20), MaxValid: intPtr(90)},
+			steps: []step{{19, 0, false}, {20, 20, true}, {90, 90, true}, {91, 0, false}},
+		},
+		{
+			name:  "ema",
+			cfg:   &FilterConfig{Type: "ema", Alpha: 0.5},
+			steps: []step{{40, 40, true}, {60, 50, true}, {60, 55, true}},
+		},
+		{
+			name:  "median",
+			cfg:   &FilterConfig{Type: "median", Window: 3},
+			steps: []step{{40, 40, true}, {90, 65, true}, {42, 42, true}, {44, 44, true}},
+		},
+		{
+			name:  "max",
+			cfg:   &FilterConfig{Type: "max", Window: 2},
+			steps: []step{{40, 40, true}, {70, 70, true}, {50, 70, true}, {45, 50, true}},
+		},
+		{
+			name:  "single spike is dropped",
+			cfg:   &FilterConfig{MaxJump: 10},
+			steps: []step{{50, 50, true}, {90, 0, false}, {51, 51, true}},
+		},
+		{
+			name:  "jump confirmed by a close second sample",
+			cfg:   &FilterConfig{MaxJump: 10},
+			steps: []step{{50, 50, true}, {70, 0, false}, {72, 72, true}},
+		},
+		{
+			name: "steady heating faster than max_jump is confirmed",
+			cfg:  &FilterConfig{MaxJump: 10},
+			steps: []step{
+				{50, 50, true}, {65, 0, false}, {80, 80, true}, {95, 0, false}, {110, 110, true},
+			},
+		},
+		{
+			name:  "jump reversed is not confirmed",
+			cfg:   &FilterConfig{MaxJump: 10},
+			steps: []step{{50, 50, true}, {70, 0, false}, {30, 0, false}, {50, 50, true}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f, err := newTempFilter(tt.cfg)
+			if err != nil {
+				t.Fatalf("newTempFilter: %v", err)
+			}
+			for n, s := range tt.steps {
+				got, ok, reason := f.update(s.raw)
+				if ok != s.accepted {
+					t.Fatalf("sample %d (%d°C): accepted=%v (%s), want %v", n, s.raw, ok, reason, s.accepted)
+				}
+				if ok && got != s.want {
+					t.Fatalf("sample %d (%d°C): filtered=%d, want %d", n, s.raw, got, s.want)
+				}
+			}
+		})
+	}
+}
+
+func TestNewTempFilterRejectsInvalidConfig(t *testing.T) {
+	tests := []struct {
+		name string
+		cfg  FilterConfig
+	}{
+		{"unknown type", FilterConfig{Type: "mean"}},
+		{"ema alpha 0", FilterConfig{Type: "ema"}},
+		{"ema alpha above 1", FilterConfig{Type: "ema", Alpha: 1.5}},
+		{"median window 0", FilterConfig{Type: "median"}},
+		{"negative max_jump", FilterConfig{MaxJump: -1}},
+		{"min above max", FilterConfig{MinValid: intPtr(80), MaxValid: intPtr(40)}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := newTempFilter(&tt.cfg); err == nil {
+				t.Fatal("expected an error")
+			}
+		})
+	}
+}
+
+func TestTempStringShowsRawAndFiltered(t *testing.T) {
+	tests := []struct {
+		name   string
+		config string
+		want   string
+	}{
+		{"no filter", `{}`, "61°C"},
+		{"filter", `{"temperature_filter": {"type": "ema", "alpha": 0.5}}`, "55°C (raw 61°C)"},
+		{"zone", `{"zones": [{"name": "front", "gpus": [0, 1]}]}`, "70°C (zone front via GPU 1; own 61°C)"},
+		{
+			"zone and filter",
+			`{"zones": [{"name": "front", "gpus": [0, 1]}], "temperature_filter": {"type": "ema", "alpha": 0.5}}`,
+			"60°C (zone front via GPU 1; own 55°C, raw 61°C)",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sim := newTestSim(t, 2, 1, []int{49, 50})
+			c := newTestController(t, tt.config, sim)
+			c.tick() // the filters start from 49°C and 50°C
+			sim.devices[0].SetTemperature(61)
+			sim.devices[1].SetTemperature(70)
+			c.tick()
+			temp := c.ownTemps[0]
+			if z := c.zoneFor(0); z != nil {
+				temp = z.temp
+			}
+			if got := c.tempString(0, temp); got != tt.want {
+				t.Fatalf("tempString=%q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,324 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"math"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/NVIDIA/go-nvml/pkg/nvml"
+)
+
+// ---------- hwmon/sysfs PWM case fans ----------
+
+const defaultHwmonRoot = "/sys/class/hwmon"
+
+// HwmonFanConfig declares one motherboard PWM output that follows a GPU.
+type HwmonFanConfig struct {
+	Name          string      `json:"name"`
+	Chip          string      `json:"chip,omitempty"`           // match hwmonN/name (e.g. "nct6798")
+	Hwmon         string      `json:"hwmon,omitempty"`          // or an explicit directory (e.g. "hwmon3")
+	PWM           int         `json:"pwm"`                      // N in pwmN
+	GPU           GPUSelector `json:"gpu"`                      // GPU whose temperature drives this fan (index, uuid:, pci: or name:)
+	RestoreEnable int         `json:"restore_enable,omitempty"` // pwmN_enable written on exit/AUTO; default: value found at startup (2 if it was manual)
+
+	DeviceConfig // optional curve, temperature_ranges, floor and hysteresis overrides
+}
+
+// hwmonFan is a single-fan Device backed by pwmN / pwmN_enable.
+// Its "temperature" is the linked GPU's temperature.
+type hwmonFan struct {
+	cfg        HwmonFanConfig
+	pwmPath    string
+	enablePath string
+	autoEnable int
+	gpu        int // resolved index of cfg.GPU
+	source     Backend
+}
+
+// hwmonBackend appends hwmon fans after the GPUs of the wrapped backend,
+// so the monitoring loop drives them like any other device.
+type hwmonBackend struct {
+	Backend
+	gpuCount int
+	fans     []*hwmonFan
+}
+
+func (hb *hwmonBackend) DeviceCount() (int, nvml.Return) {
+	return hb.gpuCount + len(hb.fans), nvml.SUCCESS
+}
+
+func (hb *hwmonBackend) DeviceByIndex(idx int) (Device, nvml.Return) {
+	if idx >= hb.gpuCount && idx < hb.gpuCount+len(hb.fans) {
+		return hb.fans[idx-hb.gpuCount], nvml.SUCCESS
+	}
+	return hb.Backend.DeviceByIndex(idx)
+}
+
+// findHwmonDir resolves the hwmonN directory for a fan entry under root.
+func findHwmonDir(root string, cfg HwmonFanConfig) (string, error) {
+	if cfg.Hwmon != "" {
+		dir := filepath.Join(root, cfg.Hwmon)
+		if _, err := os.Stat(dir); err != nil {
+			return "", err
+		}
+		return dir, nil
+	}
+	if cfg.Chip == "" {
+		return "", fmt.Errorf("either chip or hwmon must be set")
+	}
+
+	entries, err := os.ReadDir(root)
+	if err != nil {
+		return "", err
+	}
+	for _, e := range entries {
+		dir := filepath.Join(root, e.Name())
+		name, err := os.ReadFile(filepath.Join(dir, "name"))
+		if err != nil {
+			continue
+		}
+		if strings.TrimSpace(string(name)) == cfg.Chip {
+			return dir, nil
+		}
+	}
+	return "", fmt.Errorf("no hwmon chip named %q under %s", cfg.Chip, root)
+}
+
+func readSysfsInt(path string) (int, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return 0, err
+	}
+	return strconv.Atoi(strings.TrimSpace(string(data)))
+}
+
+func writeSysfsInt(path string, v int) error {
+	return os.WriteFile(path, []byte(strconv.Itoa(v)+"\n"), 0644)
+}
+
+// sysfsReturn maps a sysfs error onto the nvml.Return the loop understands.
+func sysfsReturn(err error) nvml.Return {
+	switch {
+	case err == nil:
+		return nvml.SUCCESS
+	case os.IsPermission(err):
+		return nvml.ERROR_NO_PERMISSION
+	case os.IsNotExist(err):
+		return nvml.ERROR_NOT_FOUND
+	default:
+		return nvml.ERROR_UNKNOWN
+	}
+}
+
+func initializeHwmonFans(root string, cfgs []HwmonFanConfig, gpus Backend, gpuCount int) (*hwmonBackend, error) {
+	if root == "" {
+		root = defaultHwmonRoot
+	}
+	hb := &hwmonBackend{Backend: gpus, gpuCount: gpuCount}
+
+	for _, cfg := range cfgs {
+		if cfg.Name == "" {
+			chip := cfg.Chip
+			if chip == "" {
+				chip = cfg.Hwmon
+			}
+			cfg.Name = fmt.Sprintf("%s/pwm%d", chip, cfg.PWM)
+		}
+		if cfg.GPU == "" {
+			cfg.GPU = "0"
+		}
+		gpu, err := resolveGPU(gpus, cfg.GPU)
+		if err != nil {
+			return nil, fmt.Errorf("hwmon fan %q: %w", cfg.Name, err)
+		}
+		if cfg.PWM <= 0 {
+			return nil, fmt.Errorf("hwmon fan %q: pwm must be >= 1", cfg.Name)
+		}
+
+		dir, err := findHwmonDir(root, cfg)
+		if err != nil {
+			return nil, fmt.Errorf("hwmon fan %q: %w", cfg.Name, err)
+		}
+
+		f := &hwmonFan{
+			cfg:        cfg,
+			pwmPath:    filepath.Join(dir, fmt.Sprintf("pwm%d", cfg.PWM)),
+			enablePath: filepath.Join(dir, fmt.Sprintf("pwm%d_enable", cfg.PWM)),
+			gpu:        gpu,
+			source:     gpus,
+		}
+		if _, err := readSysfsInt(f.pwmPath); err != nil {
+			return nil, fmt.Errorf("hwmon fan %q: %w", cfg.Name, err)
+		}
+		orig, err := readSysfsInt(f.enablePath)
+		if err != nil {
+			return nil, fmt.Errorf("hwmon fan %q: %w", cfg.Name, err)
+		}
+
+		// Whatever the chip used before we took over is its "automatic" mode.
+		f.autoEnable = orig
+		if orig == 1 || orig == 0 {
+			f.autoEnable = 2
+		}
+		if cfg.RestoreEnable > 0 {
+			f.autoEnable = cfg.RestoreEnable
+		}
+
+		log.Printf("INFO: hwmon fan %q: %s (pwm%d_enable=%d, auto=%d) following GPU %d (%s).",
+			cfg.Name, f.pwmPath, cfg.PWM, orig, f.autoEnable, gpu, cfg.GPU)
+		hb.fans = append(hb.fans, f)
+	}
+	return hb, nil
+}
+
+// appendState extends the per-device state from initializeDevices with the hwmon fans.
+func (hb *hwmonBackend) appendState(fanCounts []int, prevTemps []int, prevFanSpeeds [][]int) (int, []int, []int, [][]int) {
+	for _, f := range hb.fans {
+		temp, ret := f.Temperature()
+		if ret != nvml.SUCCESS {
+			temp = 0
+		}
+		speed, ret := f.FanSpeed(0)
+		if ret != nvml.SUCCESS {
+			speed = 0
+		}
+		fanCounts = append(fanCounts, 1)
+		prevTemps = append(prevTemps, temp)
+		prevFanSpeeds = append(prevFanSpeeds, []int{speed})
+		log.Printf("INFO: Initial state for hwmon fan %q: Temp=%d°C, Fan Speed=%d%%", f.cfg.Name, temp, speed)
+	}
+	return len(fanCounts), fanCounts, prevTemps, prevFanSpeeds
+}
+
+func (f *hwmonFan) overrideSettings(s *deviceSettings) {
+	s.label = fmt.Sprintf("hwmon fan %q", f.cfg.Name)
+	s.section = "hwmon_fans"
+	s.apply(f.cfg.DeviceConfig)
+}
+
+func (f *hwmonFan) UUID() (string, nvml.Return)     { return "", nvml.ERROR_NOT_SUPPORTED }
+func (f *hwmonFan) PCIBusID() (string, nvml.Return) { return "", nvml.ERROR_NOT_SUPPORTED }
+func (f *hwmonFan) Name() (string, nvml.Return)     { return f.cfg.Name, nvml.SUCCESS }
+
+func (f *hwmonFan) Temperature() (int, nvml.Return) {
+	dev, ret := f.source.DeviceByIndex(f.gpu)
+	if ret != nvml.SUCCESS {
+		return 0, ret
+	}
+	return dev.Temperature()
+}
+
+func (f *hwmonFan) MemoryTemperature() (int, nvml.Return) {
+	dev, ret := f.source.DeviceByIndex(f.gpu)
+	if ret != nvml.SUCCESS {
+		return 0, ret
+	}
+	return dev.MemoryTemperature()
+}
+
+func (f *hwmonFan) HotspotTemperature() (int, nvml.Return) {
+	dev, ret := f.source.DeviceByIndex(f.gpu)
+	if ret != nvml.SUCCESS {
+		return 0, ret
+	}
+	return dev.HotspotTemperature()
+}
+
+// CoolerInfo: a case fan's purpose is whatever the config says; it follows the device input.
+func (f *hwmonFan) CoolerInfo(fanIdx int) (coolerInfo, nvml.Return) {
+	return coolerInfo{}, nvml.ERROR_NOT_SUPPORTED
+}
+
+func (f *hwmonFan) TemperatureThreshold(kind nvml.TemperatureThresholds) (int, nvml.Return) {
+	dev, ret := f.source.DeviceByIndex(f.gpu)
+	if ret != nvml.SUCCESS {
+		return 0, ret
+	}
+	return dev.TemperatureThreshold(kind)
+}
+
+func (f *hwmonFan) PowerUsage() (int, nvml.Return) {
+	dev, ret := f.source.DeviceByIndex(f.gpu)
+	if ret != nvml.SUCCESS {
+		return 0, ret
+	}
+	return dev.PowerUsage()
+}
+
+func (f *hwmonFan) Utilization() (int, int, nvml.Return) {
+	dev, ret := f.source.DeviceByIndex(f.gpu)
+	if ret != nvml.SUCCESS {
+		return 0, 0, ret
+	}
+	return dev.Utilization()
+}
+
+func (f *hwmonFan) NumFans() (int, nvml.Return) {
+	return 1, nvml.SUCCESS
+}
+
+func (f *hwmonFan) FanSpeed(fanIdx int) (int, nvml.Return) {
+	if fanIdx != 0 {
+		return 0, nvml.ERROR_INVALID_ARGUMENT
+	}
+	pwm, err := readSysfsInt(f.pwmPath)
+	if err != nil {
+		return 0, sysfsReturn(err)
+	}
+	return int(math.Round(float64(pwm) * 100 / 255)), nvml.SUCCESS
+}
+
+func (f *hwmonFan) FanSpeedLegacy() (int, nvml.Return) {
+	return f.FanSpeed(0)
+}
+
+// FanSpeedRPM reads fanN_input next to pwmN (same N, which is the common wiring).
+func (f *hwmonFan) FanSpeedRPM(fanIdx int) (int, nvml.Return) {
+	if fanIdx != 0 {
+		return 0, nvml.ERROR_INVALID_ARGUMENT
+	}
+	rpm, err := readSysfsInt(filepath.Join(filepath.Dir(f.pwmPath), fmt.Sprintf("fan%d_input", f.cfg.PWM)))
+	if err != nil {
+		return 0, sysfsReturn(err)
+	}
+	return rpm, nvml.SUCCESS
+}
+
+func (f *hwmonFan) MinMaxFanSpeed() (int, int, nvml.Return) {
+	return 0, 100, nvml.SUCCESS
+}
+
+func (f *hwmonFan) SetFanSpeed(fanIdx, speed int) nvml.Return {
+	if fanIdx != 0 || speed < 0 || speed > 100 {
+		return nvml.ERROR_INVALID_ARGUMENT
+	}
+	pwm := int(math.Round(float64(speed) * 255 / 100))
+	if err := writeSysfsInt(f.pwmPath, pwm); err != nil {
+		log.Printf("WARN: hwmon write %s=%d failed: %v", f.pwmPath, pwm, err)
+		return sysfsReturn(err)
+	}
+	return nvml.SUCCESS
+}
+
+func (f *hwmonFan) SetDefaultFanSpeed(fanIdx int) nvml.Return {
+	return f.SetFanControlPolicy(fanIdx, nvml.FAN_POLICY_TEMPERATURE_CONTINOUS_SW)
+}
+
+func (f *hwmonFan) SetFanControlPolicy(fanIdx int, policy nvml.FanControlPolicy) nvml.Return {
+	if fanIdx != 0 {
+		return nvml.ERROR_INVALID_ARGUMENT
+	}
+	enable := 1
+	if policy != nvml.FAN_POLICY_MANUAL {
+		enable = f.autoEnable
+	}
+	if err := writeSysfsInt(f.enablePath, enable); err != nil {
+		log.Printf("WARN: hwmon write %s=%d failed: %v", f.enablePath, enable, err)
+		return sysfsReturn(err)
+	}
+	return nvml.SUCCESS
+}
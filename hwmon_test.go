@@ -0,0 +1,189 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+
+	"github.com/NVIDIA/go-nvml/pkg/nvml"
+)
+
+// fakeHwmonRoot builds a sysfs-like tree: hwmon0 is an unrelated chip and
+// hwmon3 an nct6798 whose pwm1 is at 128/255 with pwm1_enable = enable.
+func fakeHwmonRoot(t *testing.T, enable int) string {
+	t.Helper()
+	root := t.TempDir()
+	files := map[string]string{
+		"hwmon0/name":        "acpitz",
+		"hwmon3/name":        "nct6798",
+		"hwmon3/pwm1":        "128",
+		"hwmon3/pwm1_enable": strconv.Itoa(enable),
+		"hwmon3/fan1_input":  "900",
+	}
+	for name, data := range files {
+		path := filepath.Join(root, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(data+"\n"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return root
+}
+
+func readTestSysfs(t *testing.T, path string) int {
+	t.Helper()
+	v, err := readSysfsInt(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return v
+}
+
+func TestInitializeHwmonFans(t *testing.T) {
+	tests := []struct {
+		name       string
+		enable     int // pwm1_enable at startup
+		cfg        HwmonFanConfig
+		wantErr    bool
+		wantAuto   int
+		wantSpeed  int
+		wantRPM    int
+		wantGPUIdx int
+	}{
+		{name: "by chip", enable: 5, cfg: HwmonFanConfig{Chip: "nct6798", PWM: 1}, wantAuto: 5, wantSpeed: 50, wantRPM: 900},
+		{name: "by directory", enable: 2, cfg: HwmonFanConfig{Hwmon: "hwmon3", PWM: 1}, wantAuto: 2, wantSpeed: 50, wantRPM: 900},
+		{name: "manual at startup restores to 2", enable: 1, cfg: HwmonFanConfig{Chip: "nct6798", PWM: 1}, wantAuto: 2, wantSpeed: 50, wantRPM: 900},
+		{name: "restore_enable", enable: 1, cfg: HwmonFanConfig{Chip: "nct6798", PWM: 1, RestoreEnable: 5}, wantAuto: 5, wantSpeed: 50, wantRPM: 900},
+		{name: "second GPU", enable: 2, cfg: HwmonFanConfig{Chip: "nct6798", PWM: 1, GPU: "1"}, wantAuto: 2, wantSpeed: 50, wantRPM: 900, wantGPUIdx: 1},
+		{name: "unknown chip", enable: 2, cfg: HwmonFanConfig{Chip: "it8688", PWM: 1}, wantErr: true},
+		{name: "no chip or directory", enable: 2, cfg: HwmonFanConfig{PWM: 1}, wantErr: true},
+		{name: "pwm 0", enable: 2, cfg: HwmonFanConfig{Chip: "nct6798"}, wantErr: true},
+		{name: "missing pwm", enable: 2, cfg: HwmonFanConfig{Chip: "nct6798", PWM: 2}, wantErr: true},
+		{name: "unknown GPU", enable: 2, cfg: HwmonFanConfig{Chip: "nct6798", PWM: 1, GPU: "7"}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sim := newTestSim(t, 2, 1, []int{40, 60})
+			root := fakeHwmonRoot(t, tt.enable)
+			hb, err := initializeHwmonFans(root, []HwmonFanConfig{tt.cfg}, sim, 2)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatal("expected an error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if n, _ := hb.DeviceCount(); n != 3 {
+				t.Fatalf("DeviceCount=%d, want 3", n)
+			}
+			dev, _ := hb.DeviceByIndex(2)
+			f := dev.(*hwmonFan)
+			if f.autoEnable != tt.wantAuto || f.gpu != tt.wantGPUIdx {
+				t.Fatalf("auto enable %d, GPU %d; want %d, %d", f.autoEnable, f.gpu, tt.wantAuto, tt.wantGPUIdx)
+			}
+			if speed, _ := f.FanSpeed(0); speed != tt.wantSpeed {
+				t.Errorf("FanSpeed=%d%%, want %d%%", speed, tt.wantSpeed)
+			}
+			if rpm, _ := f.FanSpeedRPM(0); rpm != tt.wantRPM {
+				t.Errorf("FanSpeedRPM=%d, want %d", rpm, tt.wantRPM)
+			}
+			gpuTemp, _ := sim.devices[tt.wantGPUIdx].Temperature()
+			if temp, _ := f.Temperature(); temp != gpuTemp {
+				t.Errorf("Temperature=%d°C, want the GPU's %d°C", temp, gpuTemp)
+			}
+		})
+	}
+}
+
+func TestHwmonFanWrites(t *testing.T) {
+	tests := []struct {
+		speed, pwm int
+	}{
+		{0, 0},
+		{30, 77},
+		{50, 128},
+		{100, 255},
+	}
+	sim := newTestSim(t, 1, 1, nil)
+	root := fakeHwmonRoot(t, 5)
+	hb, err := initializeHwmonFans(root, []HwmonFanConfig{{Chip: "nct6798", PWM: 1}}, sim, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f := hb.fans[0]
+	if ret := f.SetFanControlPolicy(0, nvml.FAN_POLICY_MANUAL); ret != nvml.SUCCESS {
+		t.Fatalf("MANUAL: %v", nvml.ErrorString(ret))
+	}
+	if got := readTestSysfs(t, f.enablePath); got != 1 {
+		t.Fatalf("pwm1_enable=%d after MANUAL, want 1", got)
+	}
+	for _, tt := range tests {
+		if ret := f.SetFanSpeed(0, tt.speed); ret != nvml.SUCCESS {
+			t.Fatalf("SetFanSpeed(%d): %v", tt.speed, nvml.ErrorString(ret))
+		}
+		if got := readTestSysfs(t, f.pwmPath); got != tt.pwm {
+			t.Errorf("%d%% wrote pwm1=%d, want %d", tt.speed, got, tt.pwm)
+		}
+	}
+	if ret := f.SetFanSpeed(0, 101); ret != nvml.ERROR_INVALID_ARGUMENT {
+		t.Errorf("SetFanSpeed(101)=%v, want invalid argument", nvml.ErrorString(ret))
+	}
+	if ret := f.SetDefaultFanSpeed(0); ret != nvml.SUCCESS {
+		t.Fatalf("AUTO: %v", nvml.ErrorString(ret))
+	}
+	if got := readTestSysfs(t, f.enablePath); got != 5 {
+		t.Fatalf("pwm1_enable=%d after AUTO, want 5", got)
+	}
+}
+
+// TestHwmonFanRestoredOnShutdown drives a case fan from the loop and checks
+// that the shutdown path hands pwm1 back to the chip.
+func TestHwmonFanRestoredOnShutdown(t *testing.T) {
+	sim := newTestSim(t, 1, 1, []int{40})
+	root := fakeHwmonRoot(t, 5)
+	config, err := loadTestConfig(t, `{
+		"time_to_update": 5,
+		"hwmon_root": "`+root+`",
+		"hwmon_fans": [{
+			"name": "case",
+			"chip": "nct6798",
+			"pwm": 1,
+			"temperature_ranges": [{"min_temperature": 0, "max_temperature": 200, "fan_speed": 60, "hysteresis": 2}]
+		}],
+		"temperature_ranges": [{"min_temperature": 0, "max_temperature": 200, "fan_speed": 40, "hysteresis": 2}]
+	}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	count, fanCounts, prevTemps, prevFanSpeeds, err := initializeDevices(sim)
+	if err != nil {
+		t.Fatal(err)
+	}
+	hb, err := initializeHwmonFans(config.HwmonRoot, config.HwmonFans, sim, count)
+	if err != nil {
+		t.Fatal(err)
+	}
+	count, fanCounts, prevTemps, prevFanSpeeds = hb.appendState(fanCounts, prevTemps, prevFanSpeeds)
+	gameModeLock.Store(0)
+	c := newFanController(hb, config, count, fanCounts, prevTemps, prevFanSpeeds)
+
+	f := hb.fans[0]
+	sim.devices[0].SetTemperature(55)
+	c.tick()
+	if got := readTestSysfs(t, f.enablePath); got != 1 {
+		t.Fatalf("pwm1_enable=%d while driven, want 1", got)
+	}
+	if got := readTestSysfs(t, f.pwmPath); got != 153 {
+		t.Fatalf("pwm1=%d, want 153 (60%%)", got)
+	}
+
+	c.restoreFans()
+	if got := readTestSysfs(t, f.enablePath); got != 5 {
+		t.Fatalf("pwm1_enable=%d after shutdown, want the startup value 5", got)
+	}
+}
@@ -2,27 +2,56 @@ package main
 
 import (
 	"bufio"
+	"context"
 	"encoding/json"
+	"errors"
 	"flag"
 	"fmt"
 	"io"
 	"log"
-	"math"
 	"net"
 	"os"
+	"os/signal"
 	"sort"
 	"strconv"
 	"strings"
 	"sync/atomic"
+	"syscall"
 	"time"
 
 	"github.com/NVIDIA/go-nvml/pkg/nvml"
 )
 
 type Config struct {
-	TimeToUpdate      int                `json:"time_to_update"`
-	TemperatureRanges []TemperatureRange `json:"temperature_ranges"`
-	Curve             bool               `json:"curve"` // optional; default false => original step behavior
+	TimeToUpdate      int                      `json:"time_to_update"`
+	TemperatureRanges []TemperatureRange       `json:"temperature_ranges"`
+	Curve             bool                     `json:"curve"`                                    // optional; default false => original step behavior
+	CurvePoints       *CurveConfig             `json:"curve_points,omitempty"`                   // optional; explicit curve (floor + points), implies curve mode
+	Interpolation     string                   `json:"interpolation,omitempty"`                  // optional; curve mode: linear (default), monotone or step
+	FloorTemperature  *int                     `json:"floor_temperature,omitempty"`              // optional; curve floor (default: lowest range's max)
+	FloorHysteresis   *int                     `json:"floor_hysteresis,omitempty"`               // optional; curve floor deadband (default: lowest range's hysteresis)
+	Hysteresis        *int                     `json:"hysteresis,omitempty"`                     // optional; replaces every range's hysteresis
+	PID               *PIDConfig               `json:"pid,omitempty"`                            // optional; PID mode (overrides curve)
+	RampUpPerSecond   float64                  `json:"ramp_up_per_second,omitempty"`             // optional; max fan increase in %/s (0 = unlimited)
+	RampDownPerSecond float64                  `json:"ramp_down_per_second,omitempty"`           // optional; max fan decrease in %/s (0 = unlimited)
+	EmergencyTemp     *int                     `json:"emergency_temperature,omitempty"`          // optional; failsafe 100% at/above this temp (default: NVML slowdown - margin; <0 disables)
+	EmergencyRecovery *int                     `json:"emergency_recovery_temperature,omitempty"` // optional; leave the failsafe below this temp (default: threshold - 5)
+	EmergencyMargin   *int                     `json:"emergency_slowdown_margin,omitempty"`      // optional; margin below the NVML slowdown temp (default 5)
+	FanStop           *FanStopConfig           `json:"fan_stop,omitempty"`                       // optional; zero-RPM stop below a temperature
+	FeedForward       *FeedForwardConfig       `json:"feed_forward,omitempty"`                   // optional; raise the target from power/utilization ahead of temperature
+	SpinDown          *SpinDownConfig          `json:"spin_down,omitempty"`                      // optional; hold the peak speed, then decay, when the target falls
+	ReadFailsafe      *ReadFailsafeConfig      `json:"read_failsafe,omitempty"`                  // optional; action after repeated read failures (default: AUTO after 5)
+	TemperatureFilter *FilterConfig            `json:"temperature_filter,omitempty"`             // optional; smoothing + plausibility checks on raw samples
+	Sensor            string                   `json:"sensor,omitempty"`                         // optional; control input: core (default), mem, hotspot or e.g. "max(core, mem-10)"
+	CoolerTargets     *bool                    `json:"cooler_targets,omitempty"`                 // optional; fans whose cooler targets memory follow the memory sensor (default true; not read from NVML GPUs in this build)
+	FanSpeedRange     string                   `json:"fan_speed_range,omitempty"`                // optional; fit commands into the device min/max fan speed: clamp (default), scale or off
+	Devices           map[string]DeviceConfig  `json:"devices,omitempty"`                        // optional; keyed by GPU selector (uuid:, pci:, name:)
+	HwmonRoot         string                   `json:"hwmon_root,omitempty"`                     // optional; default /sys/class/hwmon
+	HwmonFans         []HwmonFanConfig         `json:"hwmon_fans,omitempty"`
+	CalibrationFile   string                   `json:"calibration_file,omitempty"` // optional; written by "calibrate", remaps output onto each fan's usable range
+	Zones             []ZoneConfig             `json:"zones,omitempty"`            // optional; GPUs (and hwmon fans) following one aggregated temperature
+	Schedules         []ScheduleConfig         `json:"schedules,omitempty"`        // optional; time-of-day profile switches and speed caps (first match wins)
+	Profiles          map[string]ProfileConfig `json:"profiles,omitempty"`         // optional; named control settings for schedules
 }
 
 type TemperatureRange struct {
@@ -84,36 +113,59 @@ func loadConfiguration(configPath string) (Config, error) {
 		config.TimeToUpdate = 5
 	}
 
-	if len(config.TemperatureRanges) == 0 {
+	if len(config.TemperatureRanges) == 0 && config.CurvePoints == nil {
 		log.Println("WARN: temperature_ranges is empty.")
 	}
 
+	if err := validateEmergency(config); err != nil {
+		return config, fmt.Errorf("invalid config %s: %w", configPath, err)
+	}
+	if err := validateCurves(config); err != nil {
+		return config, fmt.Errorf("invalid config %s: %w", configPath, err)
+	}
+	if err := validateFanOverrides(config); err != nil {
+		return config, fmt.Errorf("invalid config %s: %w", configPath, err)
+	}
+	if err := validateZones(config); err != nil {
+		return config, fmt.Errorf("invalid config %s: %w", configPath, err)
+	}
+	if err := validateSensors(config); err != nil {
+		return config, fmt.Errorf("invalid config %s: %w", configPath, err)
+	}
+	if err := validateFanSpeedRanges(config); err != nil {
+		return config, fmt.Errorf("invalid config %s: %w", configPath, err)
+	}
+	if err := validateSchedules(config); err != nil {
+		return config, fmt.Errorf("invalid config %s: %w", configPath, err)
+	}
+
 	log.Println("INFO: Configuration loaded and validated.")
 	return config, nil
 }
 
-func initializeNVML() (cleanupFunc func(), err error) {
-	ret := nvml.Init()
+func initializeBackend(backend Backend) (cleanupFunc func(), err error) {
+	name := backend.Name()
+	ret := backend.Init()
 	if ret != nvml.SUCCESS {
-		return nil, fmt.Errorf("unable to initialize NVML: %v", nvml.ErrorString(ret))
+		return nil, fmt.Errorf("unable to initialize %s: %v", name, nvml.ErrorString(ret))
 	}
 
 	cleanupFunc = func() {
-		log.Println("INFO: Shutting down NVML...")
-		ret := nvml.Shutdown()
+		log.Printf("INFO: Shutting down %s...", name)
+		ret := backend.Shutdown()
 		if ret != nvml.SUCCESS {
-			log.Printf("ERROR: Unable to shutdown NVML cleanly: %v", nvml.ErrorString(ret))
+			log.Printf("ERROR: Unable to shutdown %s cleanly: %v", name, nvml.ErrorString(ret))
 		} else {
-			log.Println("INFO: NVML Shutdown complete.")
+			log.Printf("INFO: %s Shutdown complete.", name)
 		}
 	}
 
-	log.Println("INFO: NVML initialized successfully.")
+	log.Printf("INFO: %s initialized successfully.", name)
 	return cleanupFunc, nil
 }
 
-func initializeDevices() (count int, fanCounts []int, prevTemps []int, prevFanSpeeds [][]int, err error) {
-	count, ret := nvml.DeviceGetCount()
+func initializeDevices(backend Backend) (count int, fanCounts []int, prevTemps []int, prevFanSpeeds [][]int, err error) {
+	count, ret := backend.DeviceCount()
 	if ret != nvml.SUCCESS {
 		return 0, nil, nil, nil, fmt.Errorf("unable to get NVIDIA device count: %v", nvml.ErrorString(ret))
 	}
@@ -128,15 +180,16 @@ func initializeDevices() (count int, fanCounts []int, prevTemps []int, prevFanSp
 	initializedDevices := 0
 
 	for i := 0; i < count; i++ {
-		device, ret := nvml.DeviceGetHandleByIndex(i)
+		device, ret := backend.DeviceByIndex(i)
 		if ret != nvml.SUCCESS {
 			log.Printf("WARN: Unable to get handle for device %d: %v. Skipping device.", i, nvml.ErrorString(ret))
 			fanCounts[i] = 0
 			continue
 		}
+		log.Printf("INFO: Device %d: %s", i, identifyDevice(device))
 
 		var numFansInt int
-		numFansInt, ret = nvml.DeviceGetNumFans(device)
+		numFansInt, ret = device.NumFans()
 		if ret != nvml.SUCCESS {
 			log.Printf("WARN: Unable to get fan count for device %d: %v. Assuming 0 fans or fan control not supported.", i, nvml.ErrorString(ret))
 			fanCounts[i] = 0
@@ -152,23 +205,23 @@ func initializeDevices() (count int, fanCounts []int, prevTemps []int, prevFanSp
 		log.Printf("INFO: Device %d has %d controllable fan(s). Initializing state.", i, fanCounts[i])
 		prevFanSpeeds[i] = make([]int, fanCounts[i])
 
-		temp, ret := nvml.DeviceGetTemperature(device, nvml.TEMPERATURE_GPU)
+		temp, ret := device.Temperature()
 		if ret == nvml.SUCCESS {
-			prevTemps[i] = int(temp)
+			prevTemps[i] = temp
 		} else {
 			log.Printf("WARN: Failed to get initial temperature for device %d: %v. Using 0.", i, nvml.ErrorString(ret))
 			prevTemps[i] = 0
 		}
 
 		for fanIdx := 0; fanIdx < fanCounts[i]; fanIdx++ {
-			speed, ret := nvml.DeviceGetFanSpeed_v2(device, fanIdx)
+			speed, ret := device.FanSpeed(fanIdx)
 			if ret == nvml.SUCCESS {
-				prevFanSpeeds[i][fanIdx] = int(speed)
+				prevFanSpeeds[i][fanIdx] = speed
 			} else {
-				speedLegacy, retLegacy := nvml.DeviceGetFanSpeed(device)
+				speedLegacy, retLegacy := device.FanSpeedLegacy()
 				if retLegacy == nvml.SUCCESS && fanIdx == 0 {
 					log.Printf("WARN: Using legacy DeviceGetFanSpeed for initial speed for device %d Fan %d.", i, fanIdx)
-					prevFanSpeeds[i][fanIdx] = int(speedLegacy)
+					prevFanSpeeds[i][fanIdx] = speedLegacy
 				} else {
 					log.Printf("WARN: Failed to get initial speed for device %d Fan %d using v2 (%v) or legacy (%v). Using 0.",
 						i, fanIdx, nvml.ErrorString(ret), nvml.ErrorString(retLegacy))
@@ -176,6 +229,7 @@ func initializeDevices() (count int, fanCounts []int, prevTemps []int, prevFanSp
 				}
 			}
 		}
+		logCoolerInfo(device, fmt.Sprintf("Device %d", i), fanCounts[i])
 		log.Printf("INFO: Initial state for device %d: Temp=%d°C, Fan Speeds=%v%%", i, prevTemps[i], prevFanSpeeds[i])
 		initializedDevices++
 	}
@@ -197,10 +251,13 @@ type curvePoint struct {
 }
 
 type curveProfile struct {
-	floorEndTemp int // temps < floorEndTemp => floorSpeed (we actually use AUTO at floor in this build)
+	floorEndTemp int // temps < floorEndTemp => floor (AUTO policy, or floorSpeed when floorManual)
 	floorSpeed   int
 	floorHyst    int
+	floorManual  bool
 	points       []curvePoint // sorted by temp; curve only between these setpoints; >= last temp => last speed
+	interp       string       // linear, monotone or step (see setInterpolation)
+	tangents     []float64    // monotone: per-point slopes in %/°C
 }
 
 func clampInt(x, lo, hi int) int {
@@ -214,12 +271,13 @@ func clampInt(x, lo, hi int) int {
 }
 
 // New curve semantics:
-// - Treat the LOWEST min_temperature range as the "floor range".
-//   Use its max_temperature as floorEndTemp, and its fan_speed as floorSpeed.
-// - Every OTHER range contributes a setpoint at (min_temperature -> fan_speed) with its hysteresis.
-// - Below floorEndTemp: floor behavior (AUTO policy in our current logic).
-// - Between setpoints: linear interpolation.
-// - Above last setpoint: fixed at last setpoint speed.
+//   - Treat the LOWEST min_temperature range as the "floor range".
+//     Use its max_temperature as floorEndTemp, and its fan_speed as floorSpeed.
+//   - Every OTHER range contributes a setpoint at (min_temperature -> fan_speed) with its hysteresis.
+//   - Below floorEndTemp: floor behavior (AUTO policy in our current logic).
+//   - Between floorEndTemp and the first setpoint: the first setpoint's speed.
+//   - Between setpoints: interpolation (linear unless "interpolation" says otherwise).
+//   - Above last setpoint: fixed at last setpoint speed.
 func buildCurveProfileFromRanges(ranges []TemperatureRange) (curveProfile, error) {
 	var prof curveProfile
 	if len(ranges) == 0 {
@@ -282,9 +340,11 @@ func curveSpeedForTempWithProfile(temp int, prof curveProfile) (int, int) {
 		return prof.floorSpeed, prof.floorHyst
 	}
 
-	// If we're between floorEndTemp and the first setpoint temp (gap), keep floor.
-	if temp < prof.points[0].temp {
-		return prof.floorSpeed, prof.floorHyst
+	// Between floorEndTemp and the first setpoint (gap), hold the first
+	// setpoint's speed: the floor speed is 0% under the AUTO floor policy, and
+	// would stop the fans above the floor.
+	if first := prof.points[0]; temp < first.temp {
+		return first.speed, first.hyst
 	}
 
 	// Ceiling clamp:
@@ -298,15 +358,10 @@ func curveSpeedForTempWithProfile(temp int, prof curveProfile) (int, int) {
 		a := prof.points[i]
 		b := prof.points[i+1]
 		if temp >= a.temp && temp < b.temp {
-			den := b.temp - a.temp
-			if den <= 0 {
+			if b.temp-a.temp <= 0 {
 				return a.speed, a.hyst
 			}
-			t := float64(temp-a.temp) / float64(den)
-			val := float64(a.speed) + t*float64(b.speed-a.speed)
-			speed := int(math.Round(val))
-			speed = clampInt(speed, 0, 100)
-			return speed, a.hyst
+			return prof.interpolate(i, temp), a.hyst
 		}
 	}
 	return last.speed, last.hyst
@@ -318,14 +373,26 @@ const gamemodeSockPath = "/run/nvidia_fan_control.gamemode.sock"
 
 // 0 = off, 1 = on
 var gameModeLock atomic.Uint32
-var gameModeSeq  atomic.Uint64  // increments on every gamemode command (on/off/status)
+var gameModeSeq atomic.Uint64 // increments on every gamemode command (on/off/status)
+
+// setGameMode applies an on/off command exactly like the socket handler does.
+func setGameMode(on bool) {
+	if on {
+		gameModeLock.Store(1)
+	} else {
+		gameModeLock.Store(0)
+	}
+	gameModeSeq.Add(1)
+}
 
-func startGamemodeSocketServer() error {
+// startGamemodeSocketServer listens for gamemode commands; stop closes the
+// listener and removes the socket.
+func startGamemodeSocketServer() (stop func(), err error) {
 	_ = os.Remove(gamemodeSockPath)
 
 	ln, err := net.Listen("unix", gamemodeSockPath)
 	if err != nil {
-		return fmt.Errorf("gamemode socket listen failed (%s): %w", gamemodeSockPath, err)
+		return nil, fmt.Errorf("gamemode socket listen failed (%s): %w", gamemodeSockPath, err)
 	}
 
 	// Let unprivileged users talk to it (no sudoers/group dance).
@@ -336,6 +403,9 @@ func startGamemodeSocketServer() error {
 	go func() {
 		for {
 			conn, err := ln.Accept()
+			if errors.Is(err, net.ErrClosed) {
+				return
+			}
 			if err != nil {
 				// If the listener dies, we can't really recover inside the daemon loop.
 				log.Printf("ERROR: Gamemode socket accept failed: %v", err)
@@ -350,13 +420,11 @@ func startGamemodeSocketServer() error {
 
 				switch cmd {
 				case "on":
-					gameModeLock.Store(1)
-					gameModeSeq.Add(1)
+					setGameMode(true)
 					_, _ = c.Write([]byte("ok\n"))
 
 				case "off":
-					gameModeLock.Store(0)
-					gameModeSeq.Add(1)
+					setGameMode(false)
 					_, _ = c.Write([]byte("ok\n"))
 
 				case "status":
@@ -375,10 +443,17 @@ func startGamemodeSocketServer() error {
 		}
 	}()
 
-	return nil
+	stop = func() {
+		_ = ln.Close()
+		if err := os.Remove(gamemodeSockPath); err != nil && !os.IsNotExist(err) {
+			log.Printf("WARN: Unable to remove gamemode socket %s: %v", gamemodeSockPath, err)
+			return
+		}
+		log.Printf("INFO: Gamemode socket %s removed.", gamemodeSockPath)
+	}
+	return stop, nil
 }
 
-
 func sendGamemodeCommand(cmd string) (string, error) {
 	conn, err := net.Dial("unix", gamemodeSockPath)
 	if err != nil {
@@ -401,259 +476,993 @@ func sendGamemodeCommand(cmd string) (string, error) {
 	return strings.TrimSpace(resp), nil
 }
 
-func runMonitoringLoop(config Config, count int, fanCounts []int, prevTemps []int, prevFanSpeeds [][]int) {
-	log.Println("INFO: Starting monitoring loop...")
+// fanController holds the per-GPU state of the monitoring loop. The daemon
+// drives tick() from a real ticker; simulate/replay drive it from a virtual clock.
+type fanController struct {
+	backend       Backend
+	config        Config
+	count         int
+	fanCounts     []int
+	prevTemps     []int
+	prevFanSpeeds [][]int
+
+	// Control settings resolved per device (GPUs use the top-level config).
+	settings []deviceSettings
+
+	// Track whether each GPU is currently below the floor (AUTO, or the floor speed) or above it (MANUAL).
+	inAuto []bool
+	// For manual-mode hysteresis on the curve target
+	lastFanChangeTemp []int
+	// Last target computed per GPU (curve or step output), for simulate/replay reporting.
+	targets []int
+	// PID controller memory per GPU (PID mode only).
+	pid []pidState
+	// Curve target a ramp-limited device is still moving toward (-1 = none, in AUTO).
+	rampGoals []int
+	// Input filter per GPU, the last raw sample it was fed and what it returned.
+	filters  []*tempFilter
+	rawTemps []int
+	ownTemps []int
+	// Control input per GPU when it is not the plain core temperature (nil = core).
+	sensors []*sensorExpr
+	// Per-fan inputs (nil = the device input), their latest readings and the
+	// readings their speed was last set at.
+	fanInputs      [][]*sensorExpr
+	fanTemps       [][]int
+	fanChangeTemps [][]int
+	// Command range per fan (device min/max fan speed).
+	limits [][]fanLimits
+	// Fans this run switched to MANUAL, handed back to the driver on shutdown.
+	touched [][]bool
+	// Over-temperature failsafe per GPU.
+	emergency   []emergencyLimit
+	inEmergency []bool
+	// Read-failure failsafe: consecutive failed updates per GPU, counting
+	// temperature reads and fan reads/commands (fanFaults, this update).
+	failsafe     ReadFailsafeConfig
+	readFailures []int
+	degraded     []bool
+	fanFaults    []string
+	// Skip the curve hysteresis once after a failsafe so the curve re-applies.
+	resync []bool
+	// Zero-RPM stop/kick timing per GPU.
+	fanStop []fanStopState
+	// Power/utilization feed-forward readings per GPU.
+	feedForward []feedForwardState
+	// Spin-down hold/decay per GPU.
+	spinDown []spinDownState
+	// Thermal zones and the zone each device follows (-1 = none).
+	zones  []*thermalZone
+	zoneOf []int
+	// Time-of-day schedules: the active one (-1 = none) and its profile, the
+	// settings each device runs outside any profile and under each profile.
+	clock           func() time.Time // injectable for simulate/replay
+	schedules       []scheduleWindow
+	schedule        int
+	profile         string
+	baseSettings    []deviceSettings
+	profileSettings map[string][]deviceSettings
+
+	// Controller clock in seconds since start (one time_to_update per tick, or
+	// the trace's time in replay) and the time since the previous tick.
+	now, dt float64
+
+	lastSeenGameModeSeq  uint64
+	lastSeenGameModeLock uint32
+}
 
-	var (
-		useCurve bool
-		prof    curveProfile
-	)
+// deviceSettings is the control configuration one device runs with.
+type deviceSettings struct {
+	label             string // log prefix, e.g. "GPU 0"
+	section           string // devices section this resolved to ("default" = top-level)
+	ranges            []TemperatureRange
+	curve             bool // requested
+	curvePoints       *CurveConfig
+	interpolation     string
+	fans              map[int]FanOverride // per-fan adjustments
+	fanCurves         map[int]curveProfile
+	floorTemp         *int
+	floorHyst         *int
+	hyst              *int
+	pid               *PIDConfig
+	rampUp            float64 // %/s, 0 = unlimited
+	rampDown          float64
+	emergencyTemp     *int
+	emergencyRecovery *int
+	emergencyMargin   *int
+	filter            *FilterConfig
+	sensor            string
+	coolerTargets     *bool
+	fanSpeedRange     string
+	fanStop           *FanStopConfig
+	feedForward       *FeedForwardConfig
+	spinDown          *SpinDownConfig
+	custom            bool // differs from the top-level config
+	useCurve          bool // curve requested and the profile is valid
+	usePID            bool // pid requested and valid (takes precedence over curve)
+	prof              curveProfile
+}
 
-	useCurve = config.Curve
-	if useCurve {
-		var err error
-		prof, err = buildCurveProfileFromRanges(config.TemperatureRanges)
-		if err != nil {
-			log.Printf("WARN: curve mode requested but invalid curve profile: %v. Falling back to step mode.", err)
-			useCurve = false
+// settingsOverrider is implemented by devices that carry their own settings (hwmon fans).
+type settingsOverrider interface {
+	overrideSettings(s *deviceSettings)
+}
+
+// resolve applies the hysteresis/floor overrides and builds the curve profile,
+// falling back to step mode when it is invalid.
+func (s *deviceSettings) resolve(logPrefix string) {
+	if s.hyst != nil {
+		rs := make([]TemperatureRange, len(s.ranges))
+		copy(rs, s.ranges)
+		for i := range rs {
+			rs[i].Hysteresis = *s.hyst
+		}
+		s.ranges = rs
+	}
+
+	s.compileFanCurves()
+	s.prof = curveProfile{}
+	s.usePID = false
+	if s.pid != nil {
+		pid := *s.pid
+		if err := pid.validate(); err != nil {
+			log.Printf("WARN: %sPID mode requested but invalid: %v. Ignoring pid settings.", logPrefix, err)
 		} else {
-			log.Printf("INFO: Curve mode enabled: floor(<%d°C)=AUTO, setpoints=%v (floor hyst=%d°C)",
-				prof.floorEndTemp, prof.points, prof.floorHyst)
+			s.pid = &pid
+			s.usePID = true
+			// Same floor as curve mode: curve_points, or lowest range and/or floor_temperature.
+			if s.curvePoints != nil {
+				s.prof = s.curvePoints.profile()
+			} else {
+				if len(s.ranges) > 0 {
+					s.prof, _ = buildCurveProfileFromRanges(s.ranges)
+				}
+				s.applyFloorOverrides()
+			}
+			log.Printf("INFO: %sPID mode enabled: target=%g°C, kp=%g ki=%g kd=%g, output=%d..%d%%, %s (floor hyst=%d°C)",
+				logPrefix, pid.Target, pid.Kp, pid.Ki, pid.Kd, pid.MinSpeed, *pid.MaxSpeed, s.prof.describeFloor(), s.prof.floorHyst)
+			return
 		}
 	}
 
-	// Track whether each GPU is currently in AUTO (below floor) or MANUAL (above floor).
-	inAuto := make([]bool, count)
-	for i := 0; i < count; i++ {
-		inAuto[i] = prevTemps[i] < prof.floorEndTemp
+	// curve_points is already validated on load and always selects curve mode.
+	if s.curvePoints != nil {
+		s.useCurve = true
+		s.prof = s.curvePoints.profile()
+		s.prof.setInterpolation(s.interpolation)
+		log.Printf("INFO: %sCurve mode enabled from curve_points: %s, %d point(s), %s interpolation (floor hyst=%d°C)",
+			logPrefix, s.prof.describeFloor(), len(s.prof.points), s.prof.interp, s.prof.floorHyst)
+		return
 	}
 
-	// For manual-mode hysteresis on the curve target
-	lastFanChangeTemp := make([]int, count)
-	copy(lastFanChangeTemp, prevTemps)
+	s.useCurve = s.curve
+	if !s.useCurve {
+		return
+	}
+	var err error
+	s.prof, err = buildCurveProfileFromRanges(s.ranges)
+	s.applyFloorOverrides()
+	s.prof.setInterpolation(s.interpolation)
+	if err != nil {
+		log.Printf("WARN: %scurve mode requested but invalid curve profile: %v. Falling back to step mode.", logPrefix, err)
+		s.useCurve = false
+	} else {
+		log.Printf("INFO: %sCurve mode enabled: temperature_ranges translated to curve_points %s, %s interpolation",
+			logPrefix, s.prof, s.prof.interp)
+	}
+}
 
-	// tiny helper for concise fan lists
-	formatFanList := func(fans []int) string {
-		if len(fans) == 0 {
-			return ""
+func (s *deviceSettings) applyFloorOverrides() {
+	if s.floorTemp != nil {
+		s.prof.floorEndTemp = *s.floorTemp
+	}
+	if s.floorHyst != nil {
+		s.prof.floorHyst = *s.floorHyst
+	}
+}
+
+func newFanController(backend Backend, config Config, count int, fanCounts []int, prevTemps []int, prevFanSpeeds [][]int) *fanController {
+	c := &fanController{
+		backend:       backend,
+		config:        config,
+		count:         count,
+		fanCounts:     fanCounts,
+		prevTemps:     prevTemps,
+		prevFanSpeeds: prevFanSpeeds,
+	}
+
+	top := topLevelSettings(config)
+	top.resolve("")
+	if top.ramped() {
+		log.Printf("INFO: Fan ramp limits: %s", top.describeRamp())
+	}
+
+	c.settings = make([]deviceSettings, count)
+	for i := 0; i < count; i++ {
+		var s deviceSettings
+		dev, ret := backend.DeviceByIndex(i)
+		if ret != nvml.SUCCESS {
+			dev = nil
 		}
-		if len(fans) == 1 {
-			return fmt.Sprintf("%d", fans[0])
+		if o, ok := dev.(settingsOverrider); ok {
+			s = topLevelSettings(config)
+			o.overrideSettings(&s)
+		} else {
+			s = settingsForGPU(config, i, dev)
 		}
-		var b strings.Builder
-		for i, f := range fans {
-			if i > 0 {
-				b.WriteString(",")
+		if s.custom {
+			log.Printf("INFO: %s uses config section %q (%s).", s.label, s.section, s.describe())
+			s.resolve(s.label + ": ")
+			for _, fanIdx := range sortedFanOverrides(s.fans) {
+				if fanIdx >= fanCounts[i] {
+					log.Printf("WARN: %s has no Fan %d (%d fan(s)); ignoring its override.", s.label, fanIdx, fanCounts[i])
+					continue
+				}
+				log.Printf("INFO: %s Fan %d override: %s.", s.label, fanIdx, s.fans[fanIdx])
 			}
-			b.WriteString(strconv.Itoa(f))
+		} else {
+			s.ranges, s.prof, s.useCurve, s.pid, s.usePID = top.ranges, top.prof, top.useCurve, top.pid, top.usePID
+		}
+		c.settings[i] = s
+	}
+
+	c.touched = make([][]bool, count)
+	for i := 0; i < count; i++ {
+		c.touched[i] = make([]bool, fanCounts[i])
+	}
+
+	c.emergency = make([]emergencyLimit, count)
+	c.inEmergency = make([]bool, count)
+	for i := 0; i < count; i++ {
+		dev, ret := backend.DeviceByIndex(i)
+		if ret != nvml.SUCCESS {
+			dev = nil
+		}
+		lim, err := resolveEmergency(c.settings[i], dev)
+		switch {
+		case err != nil:
+			log.Printf("WARN: %s: no emergency failsafe (%v); set emergency_temperature to enable it.", c.settings[i].label, err)
+		case lim.enter == 0:
+			log.Printf("INFO: %s: emergency failsafe disabled.", c.settings[i].label)
+		default:
+			log.Printf("INFO: %s: emergency failsafe at %d°C (%s), recovery below %d°C.", c.settings[i].label, lim.enter, lim.source, lim.recover)
+		}
+		c.emergency[i] = lim
+	}
+
+	c.fanStop = make([]fanStopState, count)
+	for i := 0; i < count; i++ {
+		c.fanStop[i] = newFanStopState()
+		s := &c.settings[i]
+		if s.fanStop == nil {
+			continue
+		}
+		fsc := *s.fanStop
+		if err := fsc.validate(); err != nil {
+			log.Printf("WARN: %s: invalid fan_stop: %v. Fan stop disabled.", s.label, err)
+			s.fanStop = nil
+			continue
+		}
+		s.fanStop = &fsc
+		log.Printf("INFO: %s: fan stop enabled: %s.", s.label, fsc)
+	}
+
+	c.feedForward = make([]feedForwardState, count)
+	for i := 0; i < count; i++ {
+		c.feedForward[i] = newFeedForwardState()
+		if len(prevFanSpeeds[i]) > 0 {
+			c.feedForward[i].base = prevFanSpeeds[i][0]
+		}
+		s := &c.settings[i]
+		if s.feedForward == nil {
+			continue
+		}
+		ffc := *s.feedForward
+		if err := ffc.validate(); err != nil {
+			log.Printf("WARN: %s: invalid feed_forward: %v. Feed-forward disabled.", s.label, err)
+			s.feedForward = nil
+			continue
+		}
+		s.feedForward = &ffc
+		log.Printf("INFO: %s: feed-forward enabled: %s.", s.label, ffc)
+		if dev, ret := backend.DeviceByIndex(i); ret == nvml.SUCCESS {
+			c.updateFeedForward(i, dev) // warns now if an input is unsupported
+		}
+	}
+
+	c.spinDown = make([]spinDownState, count)
+	for i := 0; i < count; i++ {
+		c.spinDown[i] = newSpinDownState()
+		s := &c.settings[i]
+		if s.spinDown == nil {
+			continue
+		}
+		if err := s.spinDown.validate(); err != nil {
+			log.Printf("WARN: %s: invalid spin_down: %v. Spin-down delay disabled.", s.label, err)
+			s.spinDown = nil
+			continue
+		}
+		if s.usePID {
+			log.Printf("INFO: %s: spin_down is ignored in PID mode.", s.label)
+			s.spinDown = nil
+			continue
+		}
+		log.Printf("INFO: %s: spin-down enabled: %s.", s.label, *s.spinDown)
+	}
+
+	fs, err := resolveReadFailsafe(config.ReadFailsafe)
+	if err != nil {
+		log.Printf("WARN: Invalid read_failsafe: %v. Using defaults.", err)
+		fs, _ = resolveReadFailsafe(nil)
+	}
+	c.failsafe = fs
+	c.readFailures = make([]int, count)
+	c.degraded = make([]bool, count)
+	c.fanFaults = make([]string, count)
+	c.resync = make([]bool, count)
+	log.Printf("INFO: Read failsafe: %s.", c.failsafe)
+
+	c.sensors = make([]*sensorExpr, count)
+	for i := 0; i < count; i++ {
+		dev, ret := backend.DeviceByIndex(i)
+		if ret != nvml.SUCCESS {
+			dev = nil
+		}
+		c.sensors[i] = c.resolveSensor(i, dev)
+	}
+
+	c.fanInputs = make([][]*sensorExpr, count)
+	c.fanTemps = make([][]int, count)
+	c.fanChangeTemps = make([][]int, count)
+	for i := 0; i < count; i++ {
+		dev, ret := backend.DeviceByIndex(i)
+		if ret != nvml.SUCCESS {
+			dev = nil
+		}
+		c.fanInputs[i] = c.resolveFanInputs(i, dev)
+		c.fanTemps[i] = make([]int, fanCounts[i])
+		c.fanChangeTemps[i] = make([]int, fanCounts[i])
+	}
+
+	c.limits = make([][]fanLimits, count)
+	for i := 0; i < count; i++ {
+		dev, ret := backend.DeviceByIndex(i)
+		if ret != nvml.SUCCESS {
+			dev = nil
+		}
+		c.limits[i] = c.resolveFanLimits(i, dev)
+	}
+
+	c.filters = make([]*tempFilter, count)
+	c.rawTemps = make([]int, count)
+	c.ownTemps = make([]int, count)
+	for i := 0; i < count; i++ {
+		f, err := newTempFilter(c.settings[i].filter)
+		if err != nil {
+			log.Printf("WARN: %s: invalid temperature_filter: %v. Using raw temperatures.", c.settings[i].label, err)
+			f, _ = newTempFilter(nil)
 		}
-		return b.String()
+		c.filters[i] = f
 	}
+	if f, err := newTempFilter(top.filter); err == nil && top.filter != nil {
+		log.Printf("INFO: Temperature filter: %s", f)
+	}
+
+	c.zones, c.zoneOf = c.resolveZones(config.Zones)
+
+	c.inAuto = make([]bool, count)
+	for i := 0; i < count; i++ {
+		c.inAuto[i] = prevTemps[i] < c.settings[i].prof.floorEndTemp
+	}
+
+	c.lastFanChangeTemp = make([]int, count)
+	copy(c.lastFanChangeTemp, prevTemps)
+
+	c.pid = make([]pidState, count)
+	c.targets = make([]int, count)
+	c.rampGoals = make([]int, count)
+	for i := 0; i < count; i++ {
+		if len(prevFanSpeeds[i]) > 0 {
+			c.targets[i] = prevFanSpeeds[i][0]
+			c.rampGoals[i] = prevFanSpeeds[i][0]
+		}
+	}
+
+	c.resolveSchedules(config)
 
 	// --- NEW: gamemode event logging (logs on on/off/status calls) ---
 	// gameModeSeq must be incremented by the command handler on EVERY gamemode command.
-	lastSeenGameModeSeq := gameModeSeq.Load()
-	lastSeenGameModeLock := gameModeLock.Load()
-	if lastSeenGameModeLock != 0 {
+	c.lastSeenGameModeSeq = gameModeSeq.Load()
+	c.lastSeenGameModeLock = gameModeLock.Load()
+	if c.lastSeenGameModeLock != 0 {
 		log.Printf("INFO: GameMode: ON (lock AUTO below floor)")
 	} else {
 		log.Printf("INFO: GameMode: OFF")
 	}
+	return c
+}
+
+// tiny helper for concise fan lists
+func formatFanList(fans []int) string {
+	if len(fans) == 0 {
+		return ""
+	}
+	if len(fans) == 1 {
+		return fmt.Sprintf("%d", fans[0])
+	}
+	var b strings.Builder
+	for i, f := range fans {
+		if i > 0 {
+			b.WriteString(",")
+		}
+		b.WriteString(strconv.Itoa(f))
+	}
+	return b.String()
+}
+
+// runMonitoringLoop drives the controller until ctx is cancelled, then hands
+// every fan it took over back to the driver.
+func runMonitoringLoop(ctx context.Context, backend Backend, config Config, count int, fanCounts []int, prevTemps []int, prevFanSpeeds [][]int) {
+	log.Println("INFO: Starting monitoring loop...")
+
+	c := newFanController(backend, config, count, fanCounts, prevTemps, prevFanSpeeds)
 
 	ticker := time.NewTicker(time.Duration(config.TimeToUpdate) * time.Second)
 	defer ticker.Stop()
 
-	for range ticker.C {
-		// --- NEW: log any gamemode command event (even if state unchanged, e.g. status) ---
-		seq := gameModeSeq.Load()
-		if seq != lastSeenGameModeSeq {
-			lock := gameModeLock.Load()
+	// systemd Type=notify: readiness, status and a watchdog fed from this loop,
+	// so a hung NVML call stops the pings and gets the service restarted.
+	lastStatus := c.statusLine()
+	if ok, err := sdNotify("READY=1\nSTATUS=" + lastStatus); err != nil {
+		log.Printf("WARN: Unable to notify systemd: %v", err)
+	} else if ok {
+		log.Println("INFO: Notified systemd: READY=1")
+	}
+	var watchdogC <-chan time.Time
+	if interval, err := sdWatchdogInterval(); err != nil {
+		log.Printf("WARN: systemd watchdog disabled: %v", err)
+	} else if interval > 0 {
+		wd := time.NewTicker(interval)
+		defer wd.Stop()
+		watchdogC = wd.C
+		log.Printf("INFO: systemd watchdog enabled: sending WATCHDOG=1 every %v.", interval)
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			_, _ = sdNotify("STOPPING=1")
+			log.Println("INFO: Shutdown requested, stopping monitoring loop and restoring fans...")
+			c.restoreFans()
+			return
+		case <-ticker.C:
+			c.tick()
+			if status := c.statusLine(); status != lastStatus {
+				_, _ = sdNotify("STATUS=" + status)
+				lastStatus = status
+			}
+		case <-watchdogC:
+			_, _ = sdNotify("WATCHDOG=1")
+		}
+	}
+}
+
+// setManual puts one fan of device i in MANUAL at speed and records it for
+// shutdown; failures are logged and reported as false.
+func (c *fanController) setManual(i int, device Device, fanIdx, speed int) bool {
+	label := c.settings[i].label
+	if c.limits[i][fanIdx].cantStop(speed) {
+		return c.stopBelowMinimum(i, device, fanIdx)
+	}
+	ret := device.SetFanControlPolicy(fanIdx, nvml.FAN_POLICY_MANUAL)
+	if ret != nvml.SUCCESS && ret != nvml.ERROR_NOT_SUPPORTED {
+		log.Printf("ERROR: Unable to set MANUAL fan policy for %s Fan %d: %v", label, fanIdx, nvml.ErrorString(ret))
+		c.fanFailed(i, fmt.Sprintf("Fan %d policy: %v", fanIdx, nvml.ErrorString(ret)))
+		return false
+	} else if ret == nvml.ERROR_NOT_SUPPORTED {
+		log.Printf("WARN: MANUAL fan policy not supported for %s Fan %d.", label, fanIdx)
+		return false
+	}
+	c.touched[i][fanIdx] = true
+	sent := c.limits[i][fanIdx].command(speed)
+	if ret := device.SetFanSpeed(fanIdx, sent); ret != nvml.SUCCESS {
+		log.Printf("ERROR: Unable to set fan speed for %s Fan %d to %d%%: %v", label, fanIdx, sent, nvml.ErrorString(ret))
+		c.fanFailed(i, fmt.Sprintf("Fan %d speed: %v", fanIdx, nvml.ErrorString(ret)))
+		return false
+	}
+	c.prevFanSpeeds[i][fanIdx] = speed
+	return true
+}
+
+// restoreFans returns every fan this run set to MANUAL to automatic control,
+// falling back to the driver default speed where the policy can't be set.
+func (c *fanController) restoreFans() {
+	for i := 0; i < c.count; i++ {
+		device, ret := c.backend.DeviceByIndex(i)
+		if ret != nvml.SUCCESS {
+			log.Printf("ERROR: Unable to get handle for device %d during shutdown: %v", i, nvml.ErrorString(ret))
+			continue
+		}
+		label := c.settings[i].label
+		for fanIdx, touched := range c.touched[i] {
+			if !touched {
+				continue
+			}
+			if how, ok := handToDriver(device, label, fanIdx); ok {
+				log.Printf("INFO: Restored %s Fan %d to %s.", label, fanIdx, how)
+			}
+		}
+	}
+}
+
+// tick runs one control cycle over every GPU, one time_to_update after the last.
+func (c *fanController) tick() {
+	c.tickAt(c.now + float64(c.config.TimeToUpdate))
+}
+
+// tickAt runs one control cycle at controller time now (seconds since start).
+// Ramps, PID, fan stop and spin-down measure time from it; replay drives it
+// from the trace's time column.
+func (c *fanController) tickAt(now float64) {
+	c.dt, c.now = now-c.now, now
+
+	// --- NEW: log any gamemode command event (even if state unchanged, e.g. status) ---
+	seq := gameModeSeq.Load()
+	if seq != c.lastSeenGameModeSeq {
+		lock := gameModeLock.Load()
+		if lock != 0 {
+			log.Printf("INFO: GameMode: ON (lock AUTO below floor)")
+		} else {
+			log.Printf("INFO: GameMode: OFF")
+		}
+		c.lastSeenGameModeSeq = seq
+		c.lastSeenGameModeLock = lock
+	} else {
+		// Optional: keep this just in case something flips lock without bumping seq
+		lock := gameModeLock.Load()
+		if lock != c.lastSeenGameModeLock {
 			if lock != 0 {
 				log.Printf("INFO: GameMode: ON (lock AUTO below floor)")
 			} else {
 				log.Printf("INFO: GameMode: OFF")
 			}
-			lastSeenGameModeSeq = seq
-			lastSeenGameModeLock = lock
-		} else {
-			// Optional: keep this just in case something flips lock without bumping seq
-			lock := gameModeLock.Load()
-			if lock != lastSeenGameModeLock {
-				if lock != 0 {
-					log.Printf("INFO: GameMode: ON (lock AUTO below floor)")
-				} else {
-					log.Printf("INFO: GameMode: OFF")
-				}
-				lastSeenGameModeLock = lock
-			}
+			c.lastSeenGameModeLock = lock
 		}
+	}
 
-		for i := 0; i < count; i++ {
-			if fanCounts[i] == 0 {
-				continue
-			}
+	c.updateSchedule()
+
+	// Read every device first so zones can aggregate this update's temperatures.
+	devices := make([]Device, c.count)
+	temps := make([]int, c.count)
+	cores := make([]int, c.count)
+	read := make([]bool, c.count)
+	for i := 0; i < c.count; i++ {
+		if c.fanCounts[i] == 0 {
+			continue
+		}
+		c.fanFaults[i] = ""
+
+		device, ret := c.backend.DeviceByIndex(i)
+		if ret != nvml.SUCCESS {
+			log.Printf("ERROR: Unable to get handle for device %d during update: %v. Skipping cycle for this device.", i, nvml.ErrorString(ret))
+			c.readFailed(i, nil, "device handle: "+nvml.ErrorString(ret))
+			continue
+		}
 
-			device, ret := nvml.DeviceGetHandleByIndex(i)
+		core, ret := device.Temperature()
+		if ret != nvml.SUCCESS {
+			log.Printf("ERROR: Unable to get temperature for device %d: %v. Skipping cycle for this device.", i, nvml.ErrorString(ret))
+			c.readFailed(i, device, "temperature: "+nvml.ErrorString(ret))
+			continue
+		}
+		raw := core
+		if e := c.sensors[i]; e != nil {
+			var failed string
+			raw, failed, ret = e.eval(func(sensor string) (int, nvml.Return) {
+				if sensor == "core" {
+					return core, nvml.SUCCESS
+				}
+				return readSensor(device, sensor)
+			})
 			if ret != nvml.SUCCESS {
-				log.Printf("ERROR: Unable to get handle for device %d during update: %v. Skipping cycle for this device.", i, nvml.ErrorString(ret))
+				log.Printf("ERROR: Unable to get %s temperature for device %d: %v. Skipping cycle for this device.", failed, i, nvml.ErrorString(ret))
+				c.readFailed(i, device, failed+" temperature: "+nvml.ErrorString(ret))
 				continue
 			}
-
-			temp, ret := nvml.DeviceGetTemperature(device, nvml.TEMPERATURE_GPU)
-			if ret != nvml.SUCCESS {
-				log.Printf("ERROR: Unable to get temperature for device %d: %v. Skipping cycle for this device.", i, nvml.ErrorString(ret))
+		}
+		if failed, ret := c.readFanInputs(i, device, core); ret != nvml.SUCCESS {
+			log.Printf("ERROR: Unable to get %s temperature for device %d: %v. Skipping cycle for this device.", failed, i, nvml.ErrorString(ret))
+			c.readFailed(i, device, failed+" temperature: "+nvml.ErrorString(ret))
+			continue
+		}
+		tempInt, ok, reason := c.filters[i].update(raw)
+		if !ok {
+			log.Printf("WARN: Ignoring implausible temperature for %s: %d°C (%s). Skipping cycle for this device.", c.settings[i].label, raw, reason)
+			// The failsafe still sees the raw core reading, or fast heating
+			// that every update rejects as a jump would never reach it.
+			if c.checkRawEmergency(i, device, core) {
 				continue
 			}
-			tempInt := int(temp)
-
-			if useCurve {
-				// --- Decide AUTO vs MANUAL using a deadband around floorEndTemp ---
-				// If we're in AUTO, only leave AUTO when temp >= floorEndTemp + floorHyst
-				// If we're in MANUAL, only enter AUTO when temp <= floorEndTemp - floorHyst
-				if inAuto[i] {
-					if tempInt >= prof.floorEndTemp+prof.floorHyst {
-						inAuto[i] = false
-
-						// Log target speed we will attempt in MANUAL at this temp (concise)
-						targetSpeed, _ := curveSpeedForTempWithProfile(tempInt, prof)
-						log.Printf("INFO: GPU %d crossing above floor: switching to MANUAL control (temp=%d°C, target=%d%%)",
-							i, tempInt, targetSpeed)
-					}
-				} else {
-					// GameMode ON => lock out MANUAL->AUTO below the floor
-					if gameModeLock.Load() == 0 {
-						if tempInt <= prof.floorEndTemp-prof.floorHyst {
-							inAuto[i] = true
-							log.Printf("INFO: GPU %d crossing below floor: switching to AUTO control (temp=%d°C)", i, tempInt)
-						}
-					}
-				}
+			c.readFailed(i, device, fmt.Sprintf("implausible temperature %d°C", raw))
+			continue
+		}
+		c.readOK(i, device, tempInt)
+		c.rawTemps[i], c.ownTemps[i] = raw, tempInt
+		devices[i], temps[i], cores[i], read[i] = device, tempInt, core, true
+	}
+	c.updateZones(temps, read)
 
-				// --- Apply policy ---
-				if inAuto[i] {
-					// Below floor => AUTO policy; do not set speed.
-					for fanIdx := 0; fanIdx < fanCounts[i]; fanIdx++ {
-						ret = nvml.DeviceSetFanControlPolicy(device, fanIdx, nvml.FAN_POLICY_TEMPERATURE_CONTINOUS_SW)
-						if ret != nvml.SUCCESS && ret != nvml.ERROR_NOT_SUPPORTED {
-							log.Printf("ERROR: Unable to set AUTO fan policy for GPU %d Fan %d: %v", i, fanIdx, nvml.ErrorString(ret))
-							continue
-						} else if ret == nvml.ERROR_NOT_SUPPORTED {
-							log.Printf("WARN: AUTO fan policy not supported for GPU %d Fan %d.", i, fanIdx)
-							continue
-						}
-					}
+	for i := 0; i < c.count; i++ {
+		if !read[i] {
+			continue
+		}
+		c.control(i, devices[i], temps[i], cores[i])
+		c.updateDone(i, devices[i], temps[i])
+	}
+}
 
-					// Reset hysteresis reference when in AUTO.
-					lastFanChangeTemp[i] = tempInt
-					prevTemps[i] = tempInt
-					continue
-				}
+// control runs the failsafes and the configured mode for device i.
+func (c *fanController) control(i int, device Device, temp, core int) {
+	// The failsafe reacts to the device's own raw sample too, so neither
+	// smoothing nor a zone average can delay it. Its threshold is a core
+	// temperature, so a memory/hotspot input does not feed it.
+	hottest := core
+	if c.sensors[i] == nil && temp > hottest {
+		hottest = temp
+	}
+	if c.checkEmergency(i, device, hottest) {
+		return
+	}
+	tempInt := c.controlTemp(i, temp)
+	// Read the load before fan stop and the floor: a large enough term restarts the fans.
+	c.updateFeedForward(i, device)
+	if c.applyFanStop(i, device, tempInt) {
+		return
+	}
 
-				// Above floor => MANUAL policy + curve target.
-				// We keep behavior identical but log concisely + aggregate same-command updates.
-				targetSpeed, hyst := curveSpeedForTempWithProfile(tempInt, prof)
+	if c.settings[i].useCurve || c.settings[i].usePID {
+		c.applyCurve(i, device, tempInt)
+	} else {
+		c.applyStep(i, device, tempInt)
+	}
+}
 
-				// Curve hysteresis: compare to last successful change temperature.
-				if abs(tempInt-lastFanChangeTemp[i]) < hyst {
-					prevTemps[i] = tempInt
-					continue
-				}
+// tempString formats a filtered temperature for logs, with the raw sample
+// alongside when a filter is active. A zone follower also shows its own
+// reading next to the zone temperature.
+func (c *fanController) tempString(i, temp int) string {
+	raw := ""
+	if c.filters[i].active() {
+		raw = fmt.Sprintf("raw %d°C", c.rawTemps[i])
+	}
+	if z := c.zoneFor(i); z != nil {
+		own := fmt.Sprintf("own %d°C", c.ownTemps[i])
+		if raw != "" {
+			own += ", " + raw
+		}
+		return fmt.Sprintf("%d°C (zone %s via %s; %s)", temp, z.name, c.settings[z.driver].label, own)
+	}
+	if raw == "" {
+		return fmt.Sprintf("%d°C", temp)
+	}
+	return fmt.Sprintf("%d°C (%s)", temp, raw)
+}
 
-				// We only update fans whose prev speed differs (same as before), but we aggregate logs.
-				changedFans := make([]int, 0, fanCounts[i])
-				for fanIdx := 0; fanIdx < fanCounts[i]; fanIdx++ {
-					if prevFanSpeeds[i][fanIdx] != targetSpeed {
-						changedFans = append(changedFans, fanIdx)
-					}
+// applyCurve runs the floor-based modes (curve and PID): AUTO below the floor,
+// MANUAL with the mode's target above it.
+func (c *fanController) applyCurve(i int, device Device, tempInt int) {
+	settings := c.settings[i]
+	prof := settings.prof
+	label := settings.label
+	inAuto := c.inAuto
+	lastFanChangeTemp := c.lastFanChangeTemp
+	prevTemps := c.prevTemps
+	prevFanSpeeds := c.prevFanSpeeds
+	fanCounts := c.fanCounts
+
+	// --- Decide AUTO vs MANUAL using a deadband around floorEndTemp ---
+	// If we're in AUTO, only leave AUTO when temp >= floorEndTemp + floorHyst
+	// (or the feed-forward term reaches its hysteresis)
+	// If we're in MANUAL, only enter AUTO when temp <= floorEndTemp - floorHyst
+	// (and the feed-forward term is back to 0)
+	if inAuto[i] {
+		hot := tempInt >= prof.floorEndTemp+prof.floorHyst
+		if hot || c.feedForwardDemand(i) {
+			inAuto[i] = false
+
+			// Log target speed we will attempt in MANUAL at this temp (concise)
+			targetSpeed, _ := curveSpeedForTempWithProfile(tempInt, prof)
+			if settings.usePID {
+				targetSpeed = settings.pid.MinSpeed // PID starts from the bottom of its range
+			}
+			targetSpeed = c.combineFeedForward(i, targetSpeed)
+			if hot {
+				log.Printf("INFO: %s crossing above floor: switching to MANUAL control (temp=%s, target=%d%%)",
+					label, c.tempString(i, tempInt), targetSpeed)
+			} else {
+				log.Printf("INFO: %s feed-forward +%d%% below floor: switching to MANUAL control (temp=%s, target=%d%%)",
+					label, c.feedForward[i].term, c.tempString(i, tempInt), targetSpeed)
+			}
+			if settings.ramped() {
+				// Ramp from whatever speed the driver was running in AUTO.
+				c.refreshFanSpeeds(i, device)
+			}
+		}
+	} else {
+		// GameMode ON => lock out MANUAL->AUTO below the floor
+		if gameModeLock.Load() == 0 {
+			if tempInt <= prof.floorEndTemp-prof.floorHyst && c.feedForward[i].term == 0 {
+				inAuto[i] = true
+				if prof.floorManual {
+					log.Printf("INFO: %s crossing below floor: holding floor speed %d%% (temp=%s)", label, prof.floorSpeed, c.tempString(i, tempInt))
+				} else {
+					log.Printf("INFO: %s crossing below floor: switching to AUTO control (temp=%s)", label, c.tempString(i, tempInt))
 				}
+			}
+		}
+	}
 
-				if len(changedFans) == 0 {
-					prevTemps[i] = tempInt
-					continue
+	// --- Apply policy ---
+	if inAuto[i] {
+		// Below floor => AUTO policy; do not set speed.
+		// (Policy "speed" instead holds the floor speed in MANUAL.)
+		floorSpeed := c.capSpeed(prof.floorSpeed)
+		for fanIdx := 0; fanIdx < fanCounts[i]; fanIdx++ {
+			if prof.floorManual {
+				if prevFanSpeeds[i][fanIdx] != floorSpeed || !c.touched[i][fanIdx] {
+					c.setManual(i, device, fanIdx, floorSpeed)
 				}
+				continue
+			}
+			ret := device.SetFanControlPolicy(fanIdx, nvml.FAN_POLICY_TEMPERATURE_CONTINOUS_SW)
+			if ret != nvml.SUCCESS && ret != nvml.ERROR_NOT_SUPPORTED {
+				log.Printf("ERROR: Unable to set AUTO fan policy for %s Fan %d: %v", label, fanIdx, nvml.ErrorString(ret))
+				c.fanFailed(i, fmt.Sprintf("Fan %d policy: %v", fanIdx, nvml.ErrorString(ret)))
+				continue
+			} else if ret == nvml.ERROR_NOT_SUPPORTED {
+				log.Printf("WARN: AUTO fan policy not supported for %s Fan %d.", label, fanIdx)
+				continue
+			}
+			c.touched[i][fanIdx] = false
+		}
 
-				anyFanUpdated := false
-				for _, fanIdx := range changedFans {
-					ret = nvml.DeviceSetFanControlPolicy(device, fanIdx, nvml.FAN_POLICY_MANUAL)
-					if ret != nvml.SUCCESS && ret != nvml.ERROR_NOT_SUPPORTED {
-						log.Printf("ERROR: Unable to set MANUAL fan policy for GPU %d Fan %d: %v", i, fanIdx, nvml.ErrorString(ret))
-						continue
-					} else if ret == nvml.ERROR_NOT_SUPPORTED {
-						log.Printf("WARN: MANUAL fan policy not supported for GPU %d Fan %d.", i, fanIdx)
-						continue
-					}
+		// Reset hysteresis reference (and PID memory, spin-down) when in AUTO.
+		c.pid[i].reset()
+		c.spinDown[i] = newSpinDownState()
+		c.rampGoals[i] = -1
+		lastFanChangeTemp[i] = tempInt
+		prevTemps[i] = tempInt
+		c.targets[i] = prof.floorSpeed
+		return
+	}
+
+	// Above floor => MANUAL policy + curve (or PID) target.
+	// We keep behavior identical but log concisely + aggregate same-command updates.
+	var targetSpeed, hyst int
+	mode := "curve"
+	if settings.usePID {
+		mode = "pid"
+		targetSpeed = c.pid[i].update(*settings.pid, float64(tempInt), c.dt)
+	} else {
+		targetSpeed, hyst = curveSpeedForTempWithProfile(tempInt, prof)
+	}
+	tempTarget := targetSpeed
+	targetSpeed = c.combineFeedForward(i, targetSpeed)
+	c.targets[i] = targetSpeed
+
+	// Curve hysteresis: compare to last successful change temperature.
+	// A ramp already under way keeps moving toward the target it accepted.
+	// A large enough feed-forward change re-applies the curve regardless.
+	// A spin-down in progress keeps holding/decaying toward the accepted target.
+	newGoal := true
+	if !c.resync[i] && !c.feedForwardChanged(i) && !c.fanInputsMoved(i, hyst) && abs(tempInt-lastFanChangeTemp[i]) < hyst {
+		if (!settings.ramped() && !c.spinningDown(i)) || c.rampGoals[i] < 0 {
+			prevTemps[i] = tempInt
+			return
+		}
+		targetSpeed, newGoal = c.rampGoals[i], false
+	}
+	c.rampGoals[i] = targetSpeed
+	c.resync[i] = false
+	if newGoal {
+		c.feedForward[i].applied = c.feedForward[i].term
+	}
+	// PID output has its own dynamics; the spin-down delay applies to curve targets only.
+	if !settings.usePID {
+		targetSpeed = c.holdSpinDown(i, targetSpeed)
+		c.targets[i] = targetSpeed
+	}
+
+	// We only update fans whose prev speed differs (same as before), but we aggregate logs.
+	// Per-fan overrides give each fan its own target; a schedule cap limits them all.
+	fanTargets := make([]int, fanCounts[i])
+	changedFans := make([]int, 0, fanCounts[i])
+	peak := 0
+	for fanIdx := 0; fanIdx < fanCounts[i]; fanIdx++ {
+		fanTargets[fanIdx] = settings.fanTarget(fanIdx, targetSpeed, tempInt)
+		if c.fanInputs[i][fanIdx] != nil && !settings.usePID {
+			ft := c.fanTemps[i][fanIdx]
+			fanTargets[fanIdx] = settings.fanTarget(fanIdx, c.fanInputTarget(i, ft), ft)
+		}
+		if fanTargets[fanIdx] > peak {
+			peak = fanTargets[fanIdx]
+		}
+		fanTargets[fanIdx] = c.capSpeed(fanTargets[fanIdx])
+		if prevFanSpeeds[i][fanIdx] != fanTargets[fanIdx] {
+			changedFans = append(changedFans, fanIdx)
+		}
+	}
 
-					ret = nvml.DeviceSetFanSpeed_v2(device, fanIdx, targetSpeed)
-					if ret != nvml.SUCCESS {
-						log.Printf("ERROR: Unable to set fan speed for GPU %d Fan %d to %d%%: %v", i, fanIdx, targetSpeed, nvml.ErrorString(ret))
-						continue
-					}
+	if len(changedFans) == 0 {
+		prevTemps[i] = tempInt
+		return
+	}
 
-					prevFanSpeeds[i][fanIdx] = targetSpeed
-					anyFanUpdated = true
-				}
+	// PID output has its own dynamics; ramp limits apply to curve targets only.
+	var updated []fanGroup
+	for _, fanIdx := range changedFans {
+		speed := fanTargets[fanIdx]
+		if !settings.usePID {
+			speed = settings.rampStep(prevFanSpeeds[i][fanIdx], fanTargets[fanIdx], c.dt)
+		}
+		if c.limits[i][fanIdx].cantStop(speed) {
+			c.stopBelowMinimum(i, device, fanIdx)
+			continue
+		}
 
-				if anyFanUpdated {
-					// One concise line for all fans that were intended to change.
-					if len(changedFans) == 1 {
-						log.Printf("INFO: Updated GPU %d Fan %d (curve): Temp=%d°C, Speed=%d%%, Hyst=%d°C",
-							i, changedFans[0], tempInt, targetSpeed, hyst)
-					} else {
-						log.Printf("INFO: Updated GPU %d Fans [%s] (curve): Temp=%d°C, Speed=%d%%, Hyst=%d°C",
-							i, formatFanList(changedFans), tempInt, targetSpeed, hyst)
-					}
+		ret := device.SetFanControlPolicy(fanIdx, nvml.FAN_POLICY_MANUAL)
+		if ret != nvml.SUCCESS && ret != nvml.ERROR_NOT_SUPPORTED {
+			log.Printf("ERROR: Unable to set MANUAL fan policy for %s Fan %d: %v", label, fanIdx, nvml.ErrorString(ret))
+			c.fanFailed(i, fmt.Sprintf("Fan %d policy: %v", fanIdx, nvml.ErrorString(ret)))
+			continue
+		} else if ret == nvml.ERROR_NOT_SUPPORTED {
+			log.Printf("WARN: MANUAL fan policy not supported for %s Fan %d.", label, fanIdx)
+			continue
+		}
+		c.touched[i][fanIdx] = true
 
-					lastFanChangeTemp[i] = tempInt
-				}
+		sent := c.limits[i][fanIdx].command(speed)
+		ret = device.SetFanSpeed(fanIdx, sent)
+		if ret != nvml.SUCCESS {
+			log.Printf("ERROR: Unable to set fan speed for %s Fan %d to %d%%: %v", label, fanIdx, sent, nvml.ErrorString(ret))
+			c.fanFailed(i, fmt.Sprintf("Fan %d speed: %v", fanIdx, nvml.ErrorString(ret)))
+			continue
+		}
 
-				prevTemps[i] = tempInt
-				continue
+		prevFanSpeeds[i][fanIdx] = speed
+		updated = groupFans(updated, fanIdx, speed, sent, fanTargets[fanIdx])
+	}
+
+	if len(updated) > 0 {
+		base := fmt.Sprintf("Hyst=%d°C", hyst)
+		if settings.usePID {
+			st := c.pid[i]
+			base = fmt.Sprintf("Setpoint=%g°C, P=%.1f I=%.1f D=%.1f", settings.pid.Target, st.p, st.i, st.d)
+		}
+		base += c.feedForwardDetail(i, tempTarget) + c.spinDownDetail(i) + c.fanInputDetail(i) + c.capDetail(peak)
+
+		// One concise line per speed the fans were actually set to.
+		for _, g := range updated {
+			detail := base + g.rampDetail() + sentDetail(g.speed, g.sent)
+			if len(g.fans) == 1 {
+				log.Printf("INFO: Updated %s Fan %d (%s): Temp=%s, Speed=%d%%, %s",
+					label, g.fans[0], mode, c.tempString(i, tempInt), g.speed, detail)
+			} else {
+				log.Printf("INFO: Updated %s Fans [%s] (%s): Temp=%s, Speed=%d%%, %s",
+					label, formatFanList(g.fans), mode, c.tempString(i, tempInt), g.speed, detail)
 			}
+		}
 
-			// --- Original step mode unchanged ---
-			for fanIdx := 0; fanIdx < fanCounts[i]; fanIdx++ {
-				prevSpeed := prevFanSpeeds[i][fanIdx]
-				newFanSpeed := getFanSpeedForTemperature(tempInt, prevTemps[i], prevSpeed, config.TemperatureRanges)
-				if newFanSpeed == prevSpeed {
-					continue
-				}
+		if newGoal {
+			lastFanChangeTemp[i] = tempInt
+			copy(c.fanChangeTemps[i], c.fanTemps[i])
+		}
+	}
 
-				ret = nvml.DeviceSetFanControlPolicy(device, fanIdx, nvml.FAN_POLICY_MANUAL)
-				if ret != nvml.SUCCESS && ret != nvml.ERROR_NOT_SUPPORTED {
-					log.Printf("ERROR: Unable to set manual fan control policy for GPU %d Fan %d: %v", i, fanIdx, nvml.ErrorString(ret))
-					continue
-				} else if ret == nvml.ERROR_NOT_SUPPORTED {
-					log.Printf("WARN: Manual fan control policy not supported for GPU %d Fan %d. Cannot set speed.", i, fanIdx)
-					continue
-				}
+	prevTemps[i] = tempInt
+}
 
-				ret = nvml.DeviceSetFanSpeed_v2(device, fanIdx, newFanSpeed)
-				if ret != nvml.SUCCESS {
-					log.Printf("ERROR: Unable to set fan speed for GPU %d Fan %d to %d%%: %v", i, fanIdx, newFanSpeed, nvml.ErrorString(ret))
-					continue
-				}
+// --- Original step mode unchanged ---
+func (c *fanController) applyStep(i int, device Device, tempInt int) {
+	settings := c.settings[i]
+	label := settings.label
+	prevTemps := c.prevTemps
+	prevFanSpeeds := c.prevFanSpeeds
+
+	// Overridden fans, feed-forward and spin-down derive their target from the device's own step target.
+	devTarget := settings.feedForward != nil || settings.spinDown != nil
+	prevBase := c.targets[i]
+	if devTarget {
+		prevBase = c.feedForward[i].base
+	}
+	base := getFanSpeedForTemperature(tempInt, prevTemps[i], prevBase, settings.ranges)
+	c.feedForward[i].base = base
+	c.targets[i] = c.holdSpinDown(i, c.combineFeedForward(i, base))
+	ffDetail := c.feedForwardDetail(i, base) + c.spinDownDetail(i) + c.fanInputDetail(i)
+
+	for fanIdx := 0; fanIdx < c.fanCounts[i]; fanIdx++ {
+		prevSpeed := prevFanSpeeds[i][fanIdx]
+		target := getFanSpeedForTemperature(tempInt, prevTemps[i], prevSpeed, settings.ranges)
+		if _, ok := settings.fans[fanIdx]; ok || devTarget {
+			target = settings.fanTarget(fanIdx, c.targets[i], tempInt)
+		}
+		if c.fanInputs[i][fanIdx] != nil {
+			// A fan with its own input runs the step table at its own temperature.
+			ft := c.fanTemps[i][fanIdx]
+			own := getFanSpeedForTemperature(ft, ft, prevSpeed, settings.ranges)
+			target = settings.fanTarget(fanIdx, c.combineFeedForward(i, own), ft)
+		}
+		capDetail := c.capDetail(target)
+		target = c.capSpeed(target)
+		if target == prevSpeed {
+			continue
+		}
+		newFanSpeed := settings.rampStep(prevSpeed, target, c.dt)
+		if c.limits[i][fanIdx].cantStop(newFanSpeed) {
+			c.stopBelowMinimum(i, device, fanIdx)
+			continue
+		}
 
-				log.Printf("INFO: Updated GPU %d Fan %d: Temp=%d°C, PrevSpeed=%d%%, NewSpeed=%d%%",
-					i, fanIdx, tempInt, prevSpeed, newFanSpeed)
+		ret := device.SetFanControlPolicy(fanIdx, nvml.FAN_POLICY_MANUAL)
+		if ret != nvml.SUCCESS && ret != nvml.ERROR_NOT_SUPPORTED {
+			log.Printf("ERROR: Unable to set manual fan control policy for %s Fan %d: %v", label, fanIdx, nvml.ErrorString(ret))
+			c.fanFailed(i, fmt.Sprintf("Fan %d policy: %v", fanIdx, nvml.ErrorString(ret)))
+			continue
+		} else if ret == nvml.ERROR_NOT_SUPPORTED {
+			log.Printf("WARN: Manual fan control policy not supported for %s Fan %d. Cannot set speed.", label, fanIdx)
+			continue
+		}
+		c.touched[i][fanIdx] = true
 
-				prevFanSpeeds[i][fanIdx] = newFanSpeed
-			}
-			prevTemps[i] = tempInt
+		sent := c.limits[i][fanIdx].command(newFanSpeed)
+		ret = device.SetFanSpeed(fanIdx, sent)
+		if ret != nvml.SUCCESS {
+			log.Printf("ERROR: Unable to set fan speed for %s Fan %d to %d%%: %v", label, fanIdx, sent, nvml.ErrorString(ret))
+			c.fanFailed(i, fmt.Sprintf("Fan %d speed: %v", fanIdx, nvml.ErrorString(ret)))
+			continue
 		}
-	}
-}
 
+		detail := ffDetail + capDetail + sentDetail(newFanSpeed, sent)
+		if newFanSpeed != target {
+			log.Printf("INFO: Updated %s Fan %d: Temp=%s, PrevSpeed=%d%%, NewSpeed=%d%%, Target=%d%% (ramping)%s",
+				label, fanIdx, c.tempString(i, tempInt), prevSpeed, newFanSpeed, target, detail)
+		} else {
+			log.Printf("INFO: Updated %s Fan %d: Temp=%s, PrevSpeed=%d%%, NewSpeed=%d%%%s",
+				label, fanIdx, c.tempString(i, tempInt), prevSpeed, newFanSpeed, detail)
+		}
 
+		prevFanSpeeds[i][fanIdx] = newFanSpeed
+	}
+	prevTemps[i] = tempInt
+}
 
 // ---------- CLI plumbing (quiet by default) ----------
 
 func printUsage() {
 	fmt.Fprintf(os.Stderr, `Usage:
-  nvidia_fan_control daemon    [-config PATH] [-log PATH] [-curve]
-  nvidia_fan_control status    [-gpu N] [-v]
-  nvidia_fan_control set       [-gpu N] [-fans "0,1"] -speed PERCENT [-v]
-  nvidia_fan_control auto      [-gpu N] [-fans "0,1"] [-v]
+  nvidia_fan_control daemon    [-config PATH] [-log PATH] [-curve] [BACKEND]
+  nvidia_fan_control status    [-gpu GPU|all] [-config PATH] [-v] [BACKEND]
+  nvidia_fan_control set       [-gpu GPU] [-fans "0,1"] -speed PERCENT [-v] [BACKEND]
+  nvidia_fan_control auto      [-gpu GPU] [-fans "0,1"] [-v] [BACKEND]
   nvidia_fan_control gamemode  on|off|status
+  nvidia_fan_control calibrate [-gpu GPU] [-fans "0,1"|all] [-out PATH] [-step PERCENT] [-poll DUR]
+                               [-settle-timeout DUR] [-v] [BACKEND]
+  nvidia_fan_control simulate  [-config PATH] [-curve] [-duration SEC] [-load "0:40,300:250"]
+                               [-gamemode "600:on"] [-fan-min N] [-fan-max N] [-clock TIME]
+                               [-csv] [-v] [MODEL]
+  nvidia_fan_control replay    [-config PATH] [-curve] -trace FILE.csv [-fans N] [-coolers LIST]
+                               [-fan-min N] [-fan-max N] [-clock TIME] [-v]
+
+GPU selectors (-gpu and "gpu" in config):
+  N | uuid:GPU-... | pci:0000:65:00.0 | name:SUBSTRING
+
+BACKEND flags (all GPU commands):
+  -backend nvml|sim    nvml (default) talks to the driver; sim is an in-memory GPU
+  -sim-gpus N          simulated GPU count (default 1)
+  -sim-fans N          fans per simulated GPU (default 2)
+  -sim-temps "45,70"   per-GPU simulated temperature in °C (default 40)
+  -sim-coolers LIST    per-fan cooler targets, e.g. "gpu,gpu,memory" (default: unsupported)
 
 daemon mode is EXACTLY the original behavior by default:
   - reads config.json from current directory
@@ -664,6 +1473,35 @@ Curve mode (daemon only):
       temps < floor.max_temperature => floor (AUTO policy)
   - uses subsequent ranges as setpoints at min_temperature
   - interpolates only between setpoints (smooth transition), with floor+ceiling clamps
+  - "curve_points" in config.json states the floor and points explicitly (implies curve mode)
+  - "interpolation": linear (default), monotone (smooth, never overshoots) or step
+
+Simulate:
+  - runs the daemon's control loop against a simulated GPU and a first-order thermal model
+  - uses a virtual clock (one row per time_to_update), so an hour simulates in milliseconds
+  - -load / -gamemode are "SECONDS:VALUE" schedules (value holds until the next entry)
+  - -fan-min/-fan-max set the min/max fan speed the simulated GPU reports (default 0..100,
+    so fan_speed_range changes nothing unless you give your card's range)
+  - -clock 2026-01-02T22:30 sets the local time the run starts at (for "schedules")
+  - MODEL flags: -ambient C, -mass J/C, -k-idle W/C, -k-fan W/C (at 100%%), -start-temp C, -fans N
+
+Replay:
+  - pushes each sample of a CSV trace through the daemon's control loop and prints every
+    fan command it would have issued (one tick per distinct time value)
+  - trace columns: temp|temp_c (required), time|time_s, gpu, gamemode (on|off),
+    power|power_w (W) and util|utilization (%%) for feed-forward
+  - the CSV written by "simulate -csv" is a valid trace
+  - -fan-min/-fan-max: as for simulate
+  - -clock sets the local time of the first sample; "schedules" follow the time column
+
+Calibrate:
+  - sweeps each fan from the driver's min to max speed, waiting for its RPM to settle at
+    every step; fans without an RPM reading are refused before any fan is touched
+  - NVML GPUs are always refused in this build (go-nvml v0.12.4 has no
+    nvmlDeviceGetFanSpeedRPM binding); only -backend sim reports RPM today
+  - records where the fan starts spinning, where it saturates and how long it settles
+  - merges the result into -out (default calibration.json) and always restores AUTO
+  - point "calibration_file" in config.json at it to map 1..100%% onto that range
 
 Gamemode toggle:
   - talks to the daemon via: /run/nvidia_fan_control.gamemode.sock
@@ -698,24 +1536,24 @@ func parseFanList(s string) ([]int, error) {
 	return out, nil
 }
 
-func deviceHandleByIndex(idx int) (nvml.Device, error) {
-	dev, ret := nvml.DeviceGetHandleByIndex(idx)
+func deviceHandleByIndex(backend Backend, idx int) (Device, error) {
+	dev, ret := backend.DeviceByIndex(idx)
 	if ret != nvml.SUCCESS {
 		return dev, fmt.Errorf("unable to get handle for device %d: %v", idx, nvml.ErrorString(ret))
 	}
 	return dev, nil
 }
 
-func getFanSpeedPercent(device nvml.Device, fanIdx int) (int, error) {
-	speed, ret := nvml.DeviceGetFanSpeed_v2(device, fanIdx)
+func getFanSpeedPercent(device Device, fanIdx int) (int, error) {
+	speed, ret := device.FanSpeed(fanIdx)
 	if ret == nvml.SUCCESS {
-		return int(speed), nil
+		return speed, nil
 	}
 
 	if fanIdx == 0 {
-		speedLegacy, retLegacy := nvml.DeviceGetFanSpeed(device)
+		speedLegacy, retLegacy := device.FanSpeedLegacy()
 		if retLegacy == nvml.SUCCESS {
-			return int(speedLegacy), nil
+			return speedLegacy, nil
 		}
 		return 0, fmt.Errorf("fan speed v2 failed (%v) and legacy failed (%v)", nvml.ErrorString(ret), nvml.ErrorString(retLegacy))
 	}
@@ -733,57 +1571,107 @@ func configureCLILogging(verbose bool) {
 	}
 }
 
-func cmdStatus(gpuIdx int, verbose bool) int {
+func cmdStatus(backend Backend, gpuSel GPUSelector, configPath string, configRequired bool, verbose bool) int {
 	configureCLILogging(verbose)
 
-	cleanup, err := initializeNVML()
+	// The config is only needed to show which devices section each GPU uses.
+	var config *Config
+	if cfg, err := loadConfig(configPath); err == nil {
+		config = &cfg
+	} else if configRequired || !os.IsNotExist(err) {
+		fmt.Fprintf(os.Stderr, "failed to load config %s: %v\n", configPath, err)
+		return 1
+	}
+
+	cleanup, err := initializeBackend(backend)
 	if err != nil {
 		fmt.Fprintln(os.Stderr, err)
 		return 1
 	}
 	defer cleanup()
 
-	count, ret := nvml.DeviceGetCount()
-	if ret != nvml.SUCCESS {
-		fmt.Fprintf(os.Stderr, "unable to get NVIDIA device count: %v\n", nvml.ErrorString(ret))
-		return 1
+	var indices []int
+	if strings.EqualFold(string(gpuSel), "all") {
+		count, ret := backend.DeviceCount()
+		if ret != nvml.SUCCESS {
+			fmt.Fprintf(os.Stderr, "unable to get NVIDIA device count: %v\n", nvml.ErrorString(ret))
+			return 1
+		}
+		for i := 0; i < count; i++ {
+			indices = append(indices, i)
+		}
+	} else {
+		gpuIdx, err := resolveGPU(backend, gpuSel)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+		indices = []int{gpuIdx}
 	}
-	if gpuIdx < 0 || gpuIdx >= count {
-		fmt.Fprintf(os.Stderr, "invalid -gpu %d (found %d device(s), valid range: 0..%d)\n", gpuIdx, count, count-1)
-		return 1
+
+	if config != nil && len(config.Schedules) > 0 {
+		fmt.Printf("Schedule: %s\n", describeSchedules(config.Schedules, time.Now()))
+	}
+
+	rc := 0
+	for _, gpuIdx := range indices {
+		if r := printGPUStatus(backend, gpuIdx, config); r != 0 {
+			rc = r
+		}
 	}
+	return rc
+}
 
-	dev, err := deviceHandleByIndex(gpuIdx)
+func printGPUStatus(backend Backend, gpuIdx int, config *Config) int {
+	dev, err := deviceHandleByIndex(backend, gpuIdx)
 	if err != nil {
 		fmt.Fprintln(os.Stderr, err)
 		return 1
 	}
 
-	temp, ret := nvml.DeviceGetTemperature(dev, nvml.TEMPERATURE_GPU)
+	temp, ret := dev.Temperature()
 	if ret != nvml.SUCCESS {
 		fmt.Fprintf(os.Stderr, "unable to get temperature for device %d: %v\n", gpuIdx, nvml.ErrorString(ret))
 		return 1
 	}
 
-	numFans, ret := nvml.DeviceGetNumFans(dev)
+	numFans, ret := dev.NumFans()
 	if ret != nvml.SUCCESS {
-		fmt.Printf("GPU %d: Temp=%d°C, Fans=unknown (DeviceGetNumFans: %v)\n", gpuIdx, int(temp), nvml.ErrorString(ret))
+		fmt.Printf("GPU %d: Temp=%d°C, Fans=unknown (DeviceGetNumFans: %v)\n", gpuIdx, temp, nvml.ErrorString(ret))
 		return 0
 	}
 
-	fmt.Printf("GPU %d: Temp=%d°C, Fans=%d\n", gpuIdx, int(temp), numFans)
+	fmt.Printf("GPU %d: Temp=%d°C, Fans=%d\n", gpuIdx, temp, numFans)
+	fmt.Printf("  Identity: %s\n", identifyDevice(dev))
+	fmt.Printf("  Sensors: %s\n", describeSensors(dev))
+	if lo, hi, ret := dev.MinMaxFanSpeed(); ret == nvml.SUCCESS {
+		fmt.Printf("  Fan speed range: %d..%d%%\n", lo, hi)
+	} else {
+		fmt.Printf("  Fan speed range: unknown (%v)\n", nvml.ErrorString(ret))
+	}
+	if config != nil {
+		s := settingsForGPU(*config, gpuIdx, dev)
+		fmt.Printf("  Config: %s (%s)\n", s.section, s.describe())
+	}
 	for fanIdx := 0; fanIdx < numFans; fanIdx++ {
+		input := ""
+		if config != nil {
+			s := settingsForGPU(*config, gpuIdx, dev)
+			if e, why := fanInputFor(s, dev, fanIdx); e != nil {
+				input = fmt.Sprintf(", input=%s (%s)", e, why)
+			}
+		}
 		speedPct, err := getFanSpeedPercent(dev, fanIdx)
 		if err != nil {
-			fmt.Printf("  Fan %d: speed=unknown (%v)\n", fanIdx, err)
+			fmt.Printf("  Fan %d: speed=unknown (%v), %s%s\n", fanIdx, err, describeCooler(dev, fanIdx), input)
 			continue
 		}
-		fmt.Printf("  Fan %d: speed=%d%%\n", fanIdx, speedPct)
+		fmt.Printf("  Fan %d: speed=%d%%, %s%s\n", fanIdx, speedPct, describeCooler(dev, fanIdx), input)
 	}
 	return 0
 }
 
-func cmdSet(gpuIdx int, fans []int, speed int, verbose bool) int {
+func cmdSet(backend Backend, gpuSel GPUSelector, fans []int, speed int, verbose bool) int {
 	configureCLILogging(verbose)
 
 	if speed < 0 || speed > 100 {
@@ -791,30 +1679,26 @@ func cmdSet(gpuIdx int, fans []int, speed int, verbose bool) int {
 		return 1
 	}
 
-	cleanup, err := initializeNVML()
+	cleanup, err := initializeBackend(backend)
 	if err != nil {
 		fmt.Fprintln(os.Stderr, err)
 		return 1
 	}
 	defer cleanup()
 
-	count, ret := nvml.DeviceGetCount()
-	if ret != nvml.SUCCESS {
-		fmt.Fprintf(os.Stderr, "unable to get NVIDIA device count: %v\n", nvml.ErrorString(ret))
-		return 1
-	}
-	if gpuIdx < 0 || gpuIdx >= count {
-		fmt.Fprintf(os.Stderr, "invalid -gpu %d (found %d device(s), valid range: 0..%d)\n", gpuIdx, count, count-1)
+	gpuIdx, err := resolveGPU(backend, gpuSel)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
 		return 1
 	}
 
-	dev, err := deviceHandleByIndex(gpuIdx)
+	dev, err := deviceHandleByIndex(backend, gpuIdx)
 	if err != nil {
 		fmt.Fprintln(os.Stderr, err)
 		return 1
 	}
 
-	numFans, ret := nvml.DeviceGetNumFans(dev)
+	numFans, ret := dev.NumFans()
 	if ret != nvml.SUCCESS {
 		fmt.Fprintf(os.Stderr, "unable to get fan count for device %d: %v\n", gpuIdx, nvml.ErrorString(ret))
 		return 1
@@ -827,8 +1711,29 @@ func cmdSet(gpuIdx int, fans []int, speed int, verbose bool) int {
 		}
 	}
 
+	// Values outside the device range are clamped; 0% on a fan that can't stop means AUTO.
+	limits, ret := queryFanLimits(dev, "clamp")
+	if ret != nvml.SUCCESS {
+		log.Printf("WARN: min/max fan speed unavailable for GPU %d (%v); sending %d%% as is.", gpuIdx, nvml.ErrorString(ret), speed)
+	}
+	label := fmt.Sprintf("GPU %d", gpuIdx)
+
 	for _, fanIdx := range fans {
-		ret = nvml.DeviceSetFanControlPolicy(dev, fanIdx, nvml.FAN_POLICY_MANUAL)
+		if limits.cantStop(speed) {
+			how, ok := handToDriver(dev, label, fanIdx)
+			if !ok {
+				fmt.Fprintf(os.Stderr, "unable to hand %s Fan %d to the driver\n", label, fanIdx)
+				return 1
+			}
+			fmt.Fprintf(os.Stderr, "%s Fan %d can't run below %d%%; handed it to %s instead of 0%%\n", label, fanIdx, limits.min, how)
+			continue
+		}
+		sent := limits.command(speed)
+		if sent != speed {
+			fmt.Fprintf(os.Stderr, "%s Fan %d accepts %d..%d%%; setting %d%% instead of %d%%\n", label, fanIdx, limits.min, limits.max, sent, speed)
+		}
+
+		ret = dev.SetFanControlPolicy(fanIdx, nvml.FAN_POLICY_MANUAL)
 		if ret != nvml.SUCCESS && ret != nvml.ERROR_NOT_SUPPORTED {
 			fmt.Fprintf(os.Stderr, "unable to set manual fan policy for GPU %d Fan %d: %v\n", gpuIdx, fanIdx, nvml.ErrorString(ret))
 			return 1
@@ -838,9 +1743,9 @@ func cmdSet(gpuIdx int, fans []int, speed int, verbose bool) int {
 			return 1
 		}
 
-		ret = nvml.DeviceSetFanSpeed_v2(dev, fanIdx, speed)
+		ret = dev.SetFanSpeed(fanIdx, sent)
 		if ret != nvml.SUCCESS {
-			fmt.Fprintf(os.Stderr, "unable to set fan speed for GPU %d Fan %d to %d%%: %v\n", gpuIdx, fanIdx, speed, nvml.ErrorString(ret))
+			fmt.Fprintf(os.Stderr, "unable to set fan speed for GPU %d Fan %d to %d%%: %v\n", gpuIdx, fanIdx, sent, nvml.ErrorString(ret))
 			return 1
 		}
 	}
@@ -848,33 +1753,29 @@ func cmdSet(gpuIdx int, fans []int, speed int, verbose bool) int {
 	return 0
 }
 
-func cmdAuto(gpuIdx int, fans []int, verbose bool) int {
+func cmdAuto(backend Backend, gpuSel GPUSelector, fans []int, verbose bool) int {
 	configureCLILogging(verbose)
 
-	cleanup, err := initializeNVML()
+	cleanup, err := initializeBackend(backend)
 	if err != nil {
 		fmt.Fprintln(os.Stderr, err)
 		return 1
 	}
 	defer cleanup()
 
-	count, ret := nvml.DeviceGetCount()
-	if ret != nvml.SUCCESS {
-		fmt.Fprintf(os.Stderr, "unable to get NVIDIA device count: %v\n", nvml.ErrorString(ret))
-		return 1
-	}
-	if gpuIdx < 0 || gpuIdx >= count {
-		fmt.Fprintf(os.Stderr, "invalid -gpu %d (found %d device(s), valid range: 0..%d)\n", gpuIdx, count, count-1)
+	gpuIdx, err := resolveGPU(backend, gpuSel)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
 		return 1
 	}
 
-	dev, err := deviceHandleByIndex(gpuIdx)
+	dev, err := deviceHandleByIndex(backend, gpuIdx)
 	if err != nil {
 		fmt.Fprintln(os.Stderr, err)
 		return 1
 	}
 
-	numFans, ret := nvml.DeviceGetNumFans(dev)
+	numFans, ret := dev.NumFans()
 	if ret != nvml.SUCCESS {
 		fmt.Fprintf(os.Stderr, "unable to get fan count for device %d: %v\n", gpuIdx, nvml.ErrorString(ret))
 		return 1
@@ -888,7 +1789,7 @@ func cmdAuto(gpuIdx int, fans []int, verbose bool) int {
 	}
 
 	for _, fanIdx := range fans {
-		ret = nvml.DeviceSetFanControlPolicy(dev, fanIdx, nvml.FAN_POLICY_TEMPERATURE_CONTINOUS_SW)
+		ret = dev.SetFanControlPolicy(fanIdx, nvml.FAN_POLICY_TEMPERATURE_CONTINOUS_SW)
 		if ret != nvml.SUCCESS && ret != nvml.ERROR_NOT_SUPPORTED {
 			fmt.Fprintf(os.Stderr, "unable to set temperature fan policy for GPU %d Fan %d: %v\n", gpuIdx, fanIdx, nvml.ErrorString(ret))
 			return 1
@@ -926,7 +1827,7 @@ func cmdGamemode(args []string) int {
 	return 0
 }
 
-func cmdDaemon(configPath, logPath string, curveOverride bool) int {
+func cmdDaemon(backend Backend, configPath, logPath string, curveOverride bool) int {
 	logFile, err := setupLogging(logPath)
 	if err != nil {
 		log.Printf("FATAL: %v", err)
@@ -934,10 +1835,15 @@ func cmdDaemon(configPath, logPath string, curveOverride bool) int {
 	}
 	defer logFile.Close()
 
+	// SIGTERM (systemctl stop) / SIGINT stop the loop so fans are handed back.
+	ctx, stopSignals := signal.NotifyContext(context.Background(), syscall.SIGTERM, syscall.SIGINT)
+	defer stopSignals()
+
 	// Start gamemode socket (one thing)
-	if err := startGamemodeSocketServer(); err != nil {
+	if stopGamemode, err := startGamemodeSocketServer(); err != nil {
 		log.Printf("WARN: Unable to start gamemode socket server: %v", err)
 	} else {
+		defer stopGamemode()
 		log.Printf("INFO: Gamemode initial state: %s", func() string {
 			if gameModeLock.Load() == 1 {
 				return "on"
@@ -955,17 +1861,33 @@ func cmdDaemon(configPath, logPath string, curveOverride bool) int {
 		config.Curve = true
 	}
 
-	nvmlCleanup, err := initializeNVML()
+	backendCleanup, err := initializeBackend(backend)
 	if err != nil {
 		log.Fatalf("FATAL: %v", err)
 	}
-	defer nvmlCleanup()
+	defer backendCleanup()
+
+	if config.CalibrationFile != "" {
+		backend, err = applyCalibration(backend, config.CalibrationFile)
+		if err != nil {
+			log.Fatalf("FATAL: Unable to load calibration: %v", err)
+		}
+	}
 
-	count, fanCounts, prevTemps, prevFanSpeeds, err := initializeDevices()
+	count, fanCounts, prevTemps, prevFanSpeeds, err := initializeDevices(backend)
 	if err != nil {
 		log.Fatalf("FATAL: %v", err)
 	}
 
+	if len(config.HwmonFans) > 0 {
+		hb, err := initializeHwmonFans(config.HwmonRoot, config.HwmonFans, backend, count)
+		if err != nil {
+			log.Fatalf("FATAL: %v", err)
+		}
+		count, fanCounts, prevTemps, prevFanSpeeds = hb.appendState(fanCounts, prevTemps, prevFanSpeeds)
+		backend = hb
+	}
+
 	hasControllableFans := false
 	for _, fc := range fanCounts {
 		if fc > 0 {
@@ -979,8 +1901,8 @@ func cmdDaemon(configPath, logPath string, curveOverride bool) int {
 		return 0
 	}
 
-	runMonitoringLoop(config, count, fanCounts, prevTemps, prevFanSpeeds)
-	log.Println("INFO: Monitoring loop finished unexpectedly.")
+	runMonitoringLoop(ctx, backend, config, count, fanCounts, prevTemps, prevFanSpeeds)
+	stopSignals() // a second signal terminates immediately
 	return 0
 }
 
@@ -993,6 +1915,7 @@ func main() {
 	switch os.Args[1] {
 	case "daemon":
 		fs := flag.NewFlagSet("daemon", flag.ContinueOnError)
+		backendOpts := registerBackendFlags(fs)
 		configPath := fs.String("config", "config.json", "Path to config.json (default preserves original behavior)")
 		logPath := fs.String("log", "/var/log/nvidia_fan_control.log", "Log file path (default preserves original behavior)")
 		curve := fs.Bool("curve", false, "Enable curve mode (overrides config)")
@@ -1000,21 +1923,40 @@ func main() {
 		if err := fs.Parse(os.Args[2:]); err != nil {
 			os.Exit(2)
 		}
-		os.Exit(cmdDaemon(*configPath, *logPath, *curve))
+		backend, err := newBackend(*backendOpts)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "daemon:", err)
+			os.Exit(2)
+		}
+		os.Exit(cmdDaemon(backend, *configPath, *logPath, *curve))
 
 	case "status":
 		fs := flag.NewFlagSet("status", flag.ContinueOnError)
-		gpuIdx := fs.Int("gpu", 0, "GPU index (default 0)")
+		backendOpts := registerBackendFlags(fs)
+		gpuSel := fs.String("gpu", "0", "GPU: index, uuid:GPU-..., pci:0000:65:00.0, name:... or all (default 0)")
+		configPath := fs.String("config", "config.json", "Config used to show each GPU's devices section (optional)")
 		verbose := fs.Bool("v", false, "Verbose (print NVML init/shutdown logs)")
 		fs.SetOutput(os.Stderr)
 		if err := fs.Parse(os.Args[2:]); err != nil {
 			os.Exit(2)
 		}
-		os.Exit(cmdStatus(*gpuIdx, *verbose))
+		backend, err := newBackend(*backendOpts)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "status:", err)
+			os.Exit(2)
+		}
+		configSet := false
+		fs.Visit(func(f *flag.Flag) {
+			if f.Name == "config" {
+				configSet = true
+			}
+		})
+		os.Exit(cmdStatus(backend, GPUSelector(*gpuSel), *configPath, configSet, *verbose))
 
 	case "set":
 		fs := flag.NewFlagSet("set", flag.ContinueOnError)
-		gpuIdx := fs.Int("gpu", 0, "GPU index (default 0)")
+		backendOpts := registerBackendFlags(fs)
+		gpuSel := fs.String("gpu", "0", "GPU: index, uuid:GPU-..., pci:0000:65:00.0 or name:... (default 0)")
 		fansStr := fs.String("fans", "0", "Comma-separated fan indices (default 0)")
 		speed := fs.Int("speed", -1, "Fan speed percent 0..100 (required)")
 		verbose := fs.Bool("v", false, "Verbose (print NVML init/shutdown logs)")
@@ -1031,11 +1973,17 @@ func main() {
 			fmt.Fprintln(os.Stderr, "set:", err)
 			os.Exit(2)
 		}
-		os.Exit(cmdSet(*gpuIdx, fans, *speed, *verbose))
+		backend, err := newBackend(*backendOpts)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "set:", err)
+			os.Exit(2)
+		}
+		os.Exit(cmdSet(backend, GPUSelector(*gpuSel), fans, *speed, *verbose))
 
 	case "auto":
 		fs := flag.NewFlagSet("auto", flag.ContinueOnError)
-		gpuIdx := fs.Int("gpu", 0, "GPU index (default 0)")
+		backendOpts := registerBackendFlags(fs)
+		gpuSel := fs.String("gpu", "0", "GPU: index, uuid:GPU-..., pci:0000:65:00.0 or name:... (default 0)")
 		fansStr := fs.String("fans", "0", "Comma-separated fan indices (default 0)")
 		verbose := fs.Bool("v", false, "Verbose (print NVML init/shutdown logs)")
 		fs.SetOutput(os.Stderr)
@@ -1047,11 +1995,85 @@ func main() {
 			fmt.Fprintln(os.Stderr, "auto:", err)
 			os.Exit(2)
 		}
-		os.Exit(cmdAuto(*gpuIdx, fans, *verbose))
+		backend, err := newBackend(*backendOpts)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "auto:", err)
+			os.Exit(2)
+		}
+		os.Exit(cmdAuto(backend, GPUSelector(*gpuSel), fans, *verbose))
 
 	case "gamemode":
 		os.Exit(cmdGamemode(os.Args[2:]))
 
+	case "calibrate":
+		fs := flag.NewFlagSet("calibrate", flag.ContinueOnError)
+		backendOpts := registerBackendFlags(fs)
+		var opts calibrateOptions
+		gpuSel := fs.String("gpu", "0", "GPU: index, uuid:GPU-..., pci:0000:65:00.0 or name:... (default 0)")
+		fs.StringVar(&opts.fans, "fans", "all", "Comma-separated fan indices or all")
+		fs.StringVar(&opts.out, "out", "calibration.json", "Calibration file to write (existing entries are kept)")
+		fs.IntVar(&opts.step, "step", 5, "Sweep step in percent")
+		fs.DurationVar(&opts.poll, "poll", 250*time.Millisecond, "Interval between fan readings")
+		fs.DurationVar(&opts.settleTimeout, "settle-timeout", 10*time.Second, "Give up when a step does not settle within this time")
+		fs.BoolVar(&opts.verbose, "v", false, "Verbose (print every reading)")
+		fs.SetOutput(os.Stderr)
+		if err := fs.Parse(os.Args[2:]); err != nil {
+			os.Exit(2)
+		}
+		opts.gpu = GPUSelector(*gpuSel)
+		backend, err := newBackend(*backendOpts)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "calibrate:", err)
+			os.Exit(2)
+		}
+		os.Exit(cmdCalibrate(backend, opts))
+
+	case "simulate":
+		fs := flag.NewFlagSet("simulate", flag.ContinueOnError)
+		var opts simulateOptions
+		fs.StringVar(&opts.configPath, "config", "config.json", "Path to config.json")
+		fs.BoolVar(&opts.curve, "curve", false, "Enable curve mode (overrides config)")
+		fs.IntVar(&opts.duration, "duration", 3600, "Simulated duration in seconds")
+		fs.IntVar(&opts.fans, "fans", 2, "Number of simulated fans")
+		fs.StringVar(&opts.load, "load", "0:40,300:250,2400:40", "Heat load schedule in watts (SECONDS:WATTS,...)")
+		fs.StringVar(&opts.gamemode, "gamemode", "", "Gamemode schedule (SECONDS:on|off,...)")
+		fs.Float64Var(&opts.ambient, "ambient", 25, "Ambient temperature (°C)")
+		fs.Float64Var(&opts.mass, "mass", 250, "Thermal mass (J/°C)")
+		fs.Float64Var(&opts.kIdle, "k-idle", 3.5, "Passive cooling (W/°C)")
+		fs.Float64Var(&opts.kFan, "k-fan", 2.0, "Extra cooling at 100% fan (W/°C)")
+		fs.Float64Var(&opts.startTemp, "start-temp", 35, "Initial GPU temperature (°C)")
+		fs.IntVar(&opts.fanMin, "fan-min", 0, "Minimum fan speed the simulated GPU reports, % (default 0 = not limited)")
+		fs.IntVar(&opts.fanMax, "fan-max", 100, "Maximum fan speed the simulated GPU reports, %")
+		fs.StringVar(&opts.clock, "clock", "", "Local time the run starts at, for schedules (YYYY-MM-DDTHH:MM, default now)")
+		fs.BoolVar(&opts.csv, "csv", false, "Output CSV instead of a table")
+		fs.BoolVar(&opts.verbose, "v", false, "Verbose (print daemon log lines to stderr)")
+		fs.SetOutput(os.Stderr)
+		if err := fs.Parse(os.Args[2:]); err != nil {
+			os.Exit(2)
+		}
+		os.Exit(cmdSimulate(opts))
+
+	case "replay":
+		fs := flag.NewFlagSet("replay", flag.ContinueOnError)
+		configPath := fs.String("config", "config.json", "Path to config.json")
+		tracePath := fs.String("trace", "", "Path to the CSV temperature trace (required)")
+		curve := fs.Bool("curve", false, "Enable curve mode (overrides config)")
+		fans := fs.Int("fans", 1, "Fans per replayed GPU")
+		coolers := fs.String("coolers", "", "Comma-separated cooler target per fan: gpu|memory|psu|all (default unsupported)")
+		fanMin := fs.Int("fan-min", 0, "Minimum fan speed the replayed GPUs report, % (default 0 = not limited)")
+		fanMax := fs.Int("fan-max", 100, "Maximum fan speed the replayed GPUs report, %")
+		clock := fs.String("clock", "", "Local time of the first sample, for schedules (YYYY-MM-DDTHH:MM, default now)")
+		verbose := fs.Bool("v", false, "Verbose (print daemon log lines to stderr)")
+		fs.SetOutput(os.Stderr)
+		if err := fs.Parse(os.Args[2:]); err != nil {
+			os.Exit(2)
+		}
+		if *tracePath == "" {
+			fmt.Fprintln(os.Stderr, "replay: -trace is required")
+			os.Exit(2)
+		}
+		os.Exit(cmdReplay(*configPath, *tracePath, *curve, *fans, *fanMin, *fanMax, *coolers, *clock, *verbose))
+
 	default:
 		printUsage()
 		os.Exit(2)
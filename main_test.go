@@ -0,0 +1,83 @@
+package main
+
+import (
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/NVIDIA/go-nvml/pkg/nvml"
+)
+
+// quietLog silences the daemon log for t.
+func quietLog(t *testing.T) {
+	log.SetOutput(io.Discard)
+	t.Cleanup(func() { log.SetOutput(os.Stderr) })
+}
+
+// newTestSim returns a simulated backend and silences the daemon log for t.
+func newTestSim(t *testing.T, gpus, fans int, temps []int) *simBackend {
+	t.Helper()
+	quietLog(t)
+	sim, err := newSimBackend(gpus, fans, temps)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return sim
+}
+
+// loadTestConfig runs configJSON through loadConfiguration and its validation.
+func loadTestConfig(t *testing.T, configJSON string) (Config, error) {
+	t.Helper()
+	quietLog(t)
+	path := filepath.Join(t.TempDir(), "config.json")
+	if err := os.WriteFile(path, []byte(configJSON), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return loadConfiguration(path)
+}
+
+// newTestController builds a controller over backend from a JSON config, the
+// same way the daemon does after loading config.json.
+func newTestController(t *testing.T, configJSON string, backend Backend) *fanController {
+	t.Helper()
+	config, err := loadTestConfig(t, configJSON)
+	if err != nil {
+		t.Fatal(err)
+	}
+	count, fanCounts, prevTemps, prevFanSpeeds, err := initializeDevices(backend)
+	if err != nil {
+		t.Fatal(err)
+	}
+	gameModeLock.Store(0)
+	return newFanController(backend, config, count, fanCounts, prevTemps, prevFanSpeeds)
+}
+
+// noManualDevice is a device whose driver refuses the MANUAL fan policy.
+type noManualDevice struct {
+	Device
+}
+
+func (noManualDevice) SetFanControlPolicy(fanIdx int, policy nvml.FanControlPolicy) nvml.Return {
+	if policy == nvml.FAN_POLICY_MANUAL {
+		return nvml.ERROR_NOT_SUPPORTED
+	}
+	return nvml.SUCCESS
+}
+
+func TestSetManualWithoutManualPolicy(t *testing.T) {
+	sim := newTestSim(t, 1, 1, []int{60})
+	c := newTestController(t, `{"time_to_update": 5}`, sim)
+	dev := noManualDevice{sim.devices[0]}
+	before, _ := sim.devices[0].FanSpeed(0)
+	if c.setManual(0, dev, 0, 80) {
+		t.Fatal("setManual succeeded without the MANUAL policy")
+	}
+	if c.touched[0][0] || c.fanFaults[0] != "" || c.prevFanSpeeds[0][0] == 80 {
+		t.Fatalf("touched=%v fault=%q prev=%d%%; want the fan left alone", c.touched[0][0], c.fanFaults[0], c.prevFanSpeeds[0][0])
+	}
+	if after, _ := sim.devices[0].FanSpeed(0); after != before {
+		t.Fatalf("fan speed changed from %d%% to %d%%", before, after)
+	}
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"fmt"
+	"math"
+)
+
+// ---------- PID mode (hold a temperature setpoint) ----------
+
+// PIDConfig enables PID mode when present. Error is temp - target, so a hot
+// GPU produces a positive error and more fan.
+type PIDConfig struct {
+	Target           float64 `json:"target"`            // °C setpoint
+	Kp               float64 `json:"kp"`                // % per °C
+	Ki               float64 `json:"ki"`                // % per °C·s
+	Kd               float64 `json:"kd"`                // % per °C/s
+	MinSpeed         int     `json:"min_speed"`         // output clamp (default 0)
+	MaxSpeed         *int    `json:"max_speed"`         // output clamp (default 100)
+	DerivativeFilter float64 `json:"derivative_filter"` // 0..1 smoothing of the derivative (0 = none)
+}
+
+func (p *PIDConfig) validate() error {
+	if p.MaxSpeed == nil {
+		hi := 100
+		p.MaxSpeed = &hi
+	}
+	if *p.MaxSpeed < 1 {
+		return fmt.Errorf("pid max_speed must be 1..100 (got %d)", *p.MaxSpeed)
+	}
+	if p.MinSpeed < 0 || *p.MaxSpeed > 100 || p.MinSpeed > *p.MaxSpeed {
+		return fmt.Errorf("pid min_speed/max_speed must satisfy 0 <= min <= max <= 100 (got %d..%d)", p.MinSpeed, *p.MaxSpeed)
+	}
+	if p.DerivativeFilter < 0 || p.DerivativeFilter >= 1 {
+		return fmt.Errorf("pid derivative_filter must be in [0,1) (got %g)", p.DerivativeFilter)
+	}
+	if p.Kp < 0 || p.Ki < 0 || p.Kd < 0 {
+		return fmt.Errorf("pid gains must be >= 0")
+	}
+	return nil
+}
+
+// pidState is the per-device controller memory.
+type pidState struct {
+	active   bool
+	integral float64 // also carries the output bias
+	prevTemp float64
+	deriv    float64 // filtered d(temp)/dt
+	p, i, d  float64 // last terms, for logging
+}
+
+// reset drops the controller memory (e.g. while the device is in AUTO).
+func (st *pidState) reset() {
+	*st = pidState{}
+}
+
+// update advances the controller by dt seconds and returns the clamped output.
+// The derivative acts on the measurement (no kick on setpoint changes) and is
+// low-pass filtered; the integral stops accumulating once the output saturates.
+func (st *pidState) update(cfg PIDConfig, temp float64, dt float64) int {
+	lo, hi := float64(cfg.MinSpeed), float64(*cfg.MaxSpeed)
+	if !st.active {
+		// Bumpless start from the bottom of the output range.
+		st.active = true
+		st.integral = lo
+		st.prevTemp = temp
+		st.deriv = 0
+	}
+	if dt <= 0 {
+		dt = 1
+	}
+
+	e := temp - cfg.Target
+	st.deriv = cfg.DerivativeFilter*st.deriv + (1-cfg.DerivativeFilter)*(temp-st.prevTemp)/dt
+	st.prevTemp = temp
+
+	st.p = cfg.Kp * e
+	st.d = cfg.Kd * st.deriv
+
+	// Anti-windup: the integral may only grow until the output reaches a clamp.
+	integral := st.integral + cfg.Ki*e*dt
+	if e > 0 {
+		integral = math.Min(integral, math.Max(st.integral, hi-st.p-st.d))
+	} else if e < 0 {
+		integral = math.Max(integral, math.Min(st.integral, lo-st.p-st.d))
+	}
+	st.integral = math.Max(lo, math.Min(hi, integral))
+	st.i = st.integral
+
+	out := st.p + st.integral + st.d
+	return clampInt(int(math.Round(out)), cfg.MinSpeed, *cfg.MaxSpeed)
+}
@@ -0,0 +1,48 @@
+package main
+
+import "testing"
+
+func TestPIDConfigValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		cfg     PIDConfig
+		wantMax int
+		wantErr bool
+	}{
+		{name: "max_speed defaults to 100", cfg: PIDConfig{Kp: 1}, wantMax: 100},
+		{name: "explicit max_speed", cfg: PIDConfig{Kp: 1, MinSpeed: 20, MaxSpeed: intPtr(70)}, wantMax: 70},
+		{name: "max_speed 0", cfg: PIDConfig{Kp: 1, MaxSpeed: intPtr(0)}, wantErr: true},
+		{name: "max_speed above 100", cfg: PIDConfig{Kp: 1, MaxSpeed: intPtr(101)}, wantErr: true},
+		{name: "min above max", cfg: PIDConfig{Kp: 1, MinSpeed: 60, MaxSpeed: intPtr(50)}, wantErr: true},
+		{name: "negative gain", cfg: PIDConfig{Kp: -1}, wantErr: true},
+		{name: "derivative_filter 1", cfg: PIDConfig{Kp: 1, DerivativeFilter: 1}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.cfg.validate()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("err=%v, want error %v", err, tt.wantErr)
+			}
+			if err == nil && *tt.cfg.MaxSpeed != tt.wantMax {
+				t.Fatalf("max_speed=%d, want %d", *tt.cfg.MaxSpeed, tt.wantMax)
+			}
+		})
+	}
+}
+
+func TestPIDOutputClamped(t *testing.T) {
+	cfg := PIDConfig{Target: 60, Kp: 10, Ki: 1, MinSpeed: 25, MaxSpeed: intPtr(70)}
+	if err := cfg.validate(); err != nil {
+		t.Fatal(err)
+	}
+	var st pidState
+	for n, temp := range []float64{60, 90, 90, 90, 30, 30} {
+		out := st.update(cfg, temp, 5)
+		if out < 25 || out > 70 {
+			t.Fatalf("update %d (%g°C): output %d%% outside 25..70%%", n, temp, out)
+		}
+	}
+	if out := st.update(cfg, 95, 5); out != 70 {
+		t.Fatalf("hot output %d%%, want the 70%% clamp", out)
+	}
+}
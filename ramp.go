@@ -0,0 +1,59 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"math"
+
+	"github.com/NVIDIA/go-nvml/pkg/nvml"
+)
+
+// ---------- Ramp-rate limiting ----------
+
+// rampStep returns the speed to command this update when moving from prev
+// toward target, limited by the ramp rates (% per second, 0 = unlimited) over
+// dt seconds. The emergency failsafe bypasses this path entirely.
+func (s deviceSettings) rampStep(prev, target int, dt float64) int {
+	rate := s.rampDown
+	if target > prev {
+		rate = s.rampUp
+	}
+	if rate <= 0 || dt <= 0 {
+		return target
+	}
+	step := int(math.Round(rate * dt))
+	if step < 1 {
+		step = 1
+	}
+	return clampInt(target, prev-step, prev+step)
+}
+
+// ramped reports whether ramp limits are configured for this device.
+func (s deviceSettings) ramped() bool {
+	return s.rampUp > 0 || s.rampDown > 0
+}
+
+// describeRamp summarizes the ramp limits for logs and status output.
+func (s deviceSettings) describeRamp() string {
+	rate := func(r float64) string {
+		if r <= 0 {
+			return "unlimited"
+		}
+		return fmt.Sprintf("%g%%/s", r)
+	}
+	return fmt.Sprintf("ramp up=%s down=%s", rate(s.rampUp), rate(s.rampDown))
+}
+
+// refreshFanSpeeds re-reads the actual fan speeds so a ramp leaving AUTO
+// starts from where the driver left the fans, not from a stale command.
+func (c *fanController) refreshFanSpeeds(i int, device Device) {
+	for fanIdx := 0; fanIdx < c.fanCounts[i]; fanIdx++ {
+		speed, ret := device.FanSpeed(fanIdx)
+		if ret != nvml.SUCCESS {
+			log.Printf("ERROR: Unable to get fan speed for %s Fan %d: %v", c.settings[i].label, fanIdx, nvml.ErrorString(ret))
+			c.fanFailed(i, fmt.Sprintf("Fan %d speed: %v", fanIdx, nvml.ErrorString(ret)))
+			continue
+		}
+		c.prevFanSpeeds[i][fanIdx] = speed
+	}
+}
@@ -0,0 +1,97 @@
+package main
+
+import "testing"
+
+func TestRampStep(t *testing.T) {
+	tests := []struct {
+		name         string
+		up, down     float64
+		prev, target int
+		dt           float64
+		want         int
+	}{
+		{"unlimited", 0, 0, 30, 90, 5, 90},
+		{"up limited", 2, 0, 30, 90, 5, 40},
+		{"up within the limit", 2, 0, 30, 35, 5, 35},
+		{"down unlimited while up is limited", 2, 0, 90, 30, 5, 30},
+		{"down limited", 0, 1, 90, 30, 5, 85},
+		{"down within the limit", 0, 1, 90, 88, 5, 88},
+		{"rate and dt scale the step", 0.5, 0, 30, 90, 10, 35},
+		{"at least 1% per update", 0.05, 0.05, 30, 90, 1, 31},
+		{"no time passed", 2, 2, 30, 90, 0, 90},
+		{"already there", 2, 2, 50, 50, 5, 50},
+	}
+	for _, tt := range tests {
+		s := deviceSettings{rampUp: tt.up, rampDown: tt.down}
+		if got := s.rampStep(tt.prev, tt.target, tt.dt); got != tt.want {
+			t.Errorf("%s: rampStep(%d, %d, %g)=%d, want %d", tt.name, tt.prev, tt.target, tt.dt, got, tt.want)
+		}
+	}
+}
+
+// TestRampStepConverges steps toward a target until it is reached: every step
+// moves toward it by at most the rate, and none passes it.
+func TestRampStepConverges(t *testing.T) {
+	tests := []struct {
+		prev, target int
+	}{
+		{20, 100},
+		{100, 20},
+		{47, 53},
+		{53, 47},
+	}
+	s := deviceSettings{rampUp: 1.3, rampDown: 0.7}
+	const dt = 5
+	for _, tt := range tests {
+		speed := tt.prev
+		for n := 0; speed != tt.target; n++ {
+			if n > 100 {
+				t.Fatalf("%d -> %d: not reached after %d updates (at %d%%)", tt.prev, tt.target, n, speed)
+			}
+			next := s.rampStep(speed, tt.target, dt)
+			limit := 7 // round(1.3 * 5)
+			if tt.target < tt.prev {
+				limit = 4 // round(0.7 * 5)
+			}
+			if abs(next-speed) > limit {
+				t.Fatalf("%d -> %d: step %d%% -> %d%% exceeds %d%%", tt.prev, tt.target, speed, next, limit)
+			}
+			if (tt.target-next)*(tt.target-tt.prev) < 0 {
+				t.Fatalf("%d -> %d: overshot to %d%%", tt.prev, tt.target, next)
+			}
+			speed = next
+		}
+	}
+}
+
+func TestRampInControlLoop(t *testing.T) {
+	sim := newTestSim(t, 1, 1, []int{30})
+	sim.setFanSpeedRange(0, 100)
+	c := newTestController(t, `{
+		"time_to_update": 5,
+		"ramp_up_per_second": 1,
+		"ramp_down_per_second": 2,
+		"emergency_temperature": 90,
+		"temperature_ranges": [
+			{"min_temperature": 0, "max_temperature": 50, "fan_speed": 30, "hysteresis": 2},
+			{"min_temperature": 50, "max_temperature": 200, "fan_speed": 80, "hysteresis": 2}
+		]
+	}`, sim)
+	steps := []struct {
+		temp, want int
+	}{
+		{35, 30},
+		{60, 35}, // up 1%/s over 5s
+		{61, 40},
+		{91, 100}, // the emergency is not ramped
+		{40, 90},  // down 2%/s once it ends
+		{40, 80},
+	}
+	for n, s := range steps {
+		sim.devices[0].SetTemperature(s.temp)
+		c.tick()
+		if got, _ := sim.devices[0].FanSpeed(0); got != s.want {
+			t.Fatalf("update %d (%d°C): fan at %d%%, want %d%%", n, s.temp, got, s.want)
+		}
+	}
+}
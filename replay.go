@@ -0,0 +1,319 @@
+package main
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/NVIDIA/go-nvml/pkg/nvml"
+)
+
+// ---------- replay: recorded temperature traces through the control loop ----------
+
+// traceSample is one row of a trace: the temperature of one GPU at time t.
+type traceSample struct {
+	t        int // seconds (or row number when the trace has no time column)
+	gpu      int
+	temp     int
+	gamemode string  // "on", "off" or "" (unchanged)
+	watts    float64 // -1 = not in the trace
+	util     int     // -1 = not in the trace
+	mem      int     // -1 = not in the trace (the sim derives it from temp)
+	hotspot  int     // -1 = not in the trace
+}
+
+// loadTrace reads a CSV trace with a header row. Recognized columns:
+//
+//	temp | temp_c             required, °C (fractional values are rounded)
+//	time | time_s             optional, seconds; rows sharing a time form one tick
+//	                          (without it, every row is one time_to_update)
+//	gpu                       optional, GPU index (default 0)
+//	gamemode                  optional, on|off|1|0 (blank = unchanged)
+//	power | power_w           optional, board power in W (feed-forward input)
+//	util | utilization        optional, GPU utilization in % (feed-forward input)
+//	mem | mem_c               optional, memory temperature in °C ("sensor" input)
+//	hotspot | hotspot_c       optional, hotspot temperature in °C ("sensor" input)
+//
+// The CSV written by `simulate -csv` is a valid trace. timed reports whether
+// the trace has a time column.
+func loadTrace(r io.Reader) (samples []traceSample, timed bool, err error) {
+	cr := csv.NewReader(r)
+	cr.TrimLeadingSpace = true
+	cr.FieldsPerRecord = -1
+
+	header, err := cr.Read()
+	if err != nil {
+		return nil, false, fmt.Errorf("unable to read trace header: %w", err)
+	}
+	col := map[string]int{}
+	for i, h := range header {
+		col[strings.ToLower(strings.TrimSpace(h))] = i
+	}
+	lookup := func(names ...string) int {
+		for _, n := range names {
+			if i, ok := col[n]; ok {
+				return i
+			}
+		}
+		return -1
+	}
+	tempCol := lookup("temp", "temp_c")
+	timeCol := lookup("time", "time_s")
+	gpuCol := lookup("gpu")
+	gmCol := lookup("gamemode")
+	powerCol := lookup("power", "power_w")
+	utilCol := lookup("util", "utilization")
+	memCol := lookup("mem", "mem_c")
+	hotCol := lookup("hotspot", "hotspot_c")
+	if tempCol < 0 {
+		return nil, false, fmt.Errorf("trace header %v has no temp/temp_c column", header)
+	}
+
+	field := func(rec []string, i int) string {
+		if i < 0 || i >= len(rec) {
+			return ""
+		}
+		return strings.TrimSpace(rec[i])
+	}
+
+	var out []traceSample
+	for row := 1; ; row++ {
+		rec, err := cr.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, false, fmt.Errorf("trace row %d: %w", row, err)
+		}
+
+		s := traceSample{t: row - 1, watts: -1, util: -1, mem: -1, hotspot: -1}
+
+		temp, err := strconv.ParseFloat(field(rec, tempCol), 64)
+		if err != nil {
+			return nil, false, fmt.Errorf("trace row %d: invalid temperature %q", row, field(rec, tempCol))
+		}
+		s.temp = int(math.Round(temp))
+
+		if v := field(rec, timeCol); timeCol >= 0 {
+			t, err := strconv.ParseFloat(v, 64)
+			if err != nil {
+				return nil, false, fmt.Errorf("trace row %d: invalid time %q", row, v)
+			}
+			s.t = int(math.Round(t))
+		}
+		if v := field(rec, gpuCol); v != "" {
+			s.gpu, err = strconv.Atoi(v)
+			if err != nil || s.gpu < 0 {
+				return nil, false, fmt.Errorf("trace row %d: invalid gpu %q", row, v)
+			}
+		}
+		if v := field(rec, powerCol); v != "" {
+			s.watts, err = strconv.ParseFloat(v, 64)
+			if err != nil || s.watts < 0 {
+				return nil, false, fmt.Errorf("trace row %d: invalid power %q", row, v)
+			}
+		}
+		if v := field(rec, utilCol); v != "" {
+			u, err := strconv.ParseFloat(v, 64)
+			if err != nil || u < 0 || u > 100 {
+				return nil, false, fmt.Errorf("trace row %d: invalid utilization %q", row, v)
+			}
+			s.util = int(math.Round(u))
+		}
+		for _, extra := range []struct {
+			col  int
+			name string
+			dst  *int
+		}{{memCol, "memory temperature", &s.mem}, {hotCol, "hotspot temperature", &s.hotspot}} {
+			if v := field(rec, extra.col); v != "" {
+				t, err := strconv.ParseFloat(v, 64)
+				if err != nil || t < 0 {
+					return nil, false, fmt.Errorf("trace row %d: invalid %s %q", row, extra.name, v)
+				}
+				*extra.dst = int(math.Round(t))
+			}
+		}
+		switch strings.ToLower(field(rec, gmCol)) {
+		case "":
+		case "on", "1", "true":
+			s.gamemode = "on"
+		case "off", "0", "false":
+			s.gamemode = "off"
+		default:
+			return nil, false, fmt.Errorf("trace row %d: invalid gamemode %q (expected on|off)", row, field(rec, gmCol))
+		}
+
+		if len(out) > 0 && s.t < out[len(out)-1].t {
+			return nil, false, fmt.Errorf("trace row %d: time %d goes backwards", row, s.t)
+		}
+		out = append(out, s)
+	}
+	if len(out) == 0 {
+		return nil, false, fmt.Errorf("trace has no samples")
+	}
+	return out, timeCol >= 0, nil
+}
+
+// recordingBackend wraps a backend and prints every fan command issued to it,
+// stamped with the trace time from now.
+type recordingBackend struct {
+	Backend
+	out io.Writer
+	now func() int
+}
+
+type recordingDevice struct {
+	Device
+	rb  *recordingBackend
+	gpu int
+}
+
+func (rb *recordingBackend) DeviceByIndex(idx int) (Device, nvml.Return) {
+	dev, ret := rb.Backend.DeviceByIndex(idx)
+	if ret != nvml.SUCCESS {
+		return dev, ret
+	}
+	return recordingDevice{Device: dev, rb: rb, gpu: idx}, ret
+}
+
+func (d recordingDevice) SetFanControlPolicy(fanIdx int, policy nvml.FanControlPolicy) nvml.Return {
+	ret := d.Device.SetFanControlPolicy(fanIdx, policy)
+	name := "AUTO"
+	if policy == nvml.FAN_POLICY_MANUAL {
+		name = "MANUAL"
+	}
+	d.record(fanIdx, "policy "+name, ret)
+	return ret
+}
+
+func (d recordingDevice) SetFanSpeed(fanIdx, speed int) nvml.Return {
+	ret := d.Device.SetFanSpeed(fanIdx, speed)
+	d.record(fanIdx, fmt.Sprintf("speed %d%%", speed), ret)
+	return ret
+}
+
+func (d recordingDevice) SetDefaultFanSpeed(fanIdx int) nvml.Return {
+	ret := d.Device.SetDefaultFanSpeed(fanIdx)
+	d.record(fanIdx, "default speed", ret)
+	return ret
+}
+
+func (d recordingDevice) record(fanIdx int, what string, ret nvml.Return) {
+	suffix := ""
+	if ret != nvml.SUCCESS {
+		suffix = " (" + nvml.ErrorString(ret) + ")"
+	}
+	fmt.Fprintf(d.rb.out, "t=%ds GPU %d Fan %d: %s%s\n", d.rb.now(), d.gpu, fanIdx, what, suffix)
+}
+
+func cmdReplay(configPath, tracePath string, curveOverride bool, fans, fanMin, fanMax int, coolers, clock string, verbose bool) int {
+	configureCLILogging(verbose)
+
+	config, err := loadConfiguration(configPath)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	if curveOverride {
+		config.Curve = true
+	}
+	start, err := parseClockFlag(clock)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "replay:", err)
+		return 2
+	}
+
+	f, err := os.Open(tracePath)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	samples, timed, err := loadTrace(f)
+	f.Close()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s: %v\n", tracePath, err)
+		return 1
+	}
+
+	gpus := 0
+	for _, s := range samples {
+		if s.gpu+1 > gpus {
+			gpus = s.gpu + 1
+		}
+	}
+	initTemps := make([]int, gpus)
+	seen := make([]bool, gpus)
+	for _, s := range samples {
+		if !seen[s.gpu] {
+			initTemps[s.gpu] = s.temp
+			seen[s.gpu] = true
+		}
+	}
+
+	sim, err := newSimBackend(gpus, fans, initTemps)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "replay:", err)
+		return 2
+	}
+	if err := sim.setCoolerTargets(coolers); err != nil {
+		fmt.Fprintln(os.Stderr, "replay: invalid -coolers:", err)
+		return 2
+	}
+	if err := sim.setFanSpeedRange(fanMin, fanMax); err != nil {
+		fmt.Fprintln(os.Stderr, "replay: invalid -fan-min/-fan-max:", err)
+		return 2
+	}
+	// The controller runs on the trace's time column (rows are one
+	// time_to_update apart without one); schedules start at -clock.
+	now := samples[0].t
+	first, scale := now, 1
+	if !timed {
+		scale = config.TimeToUpdate
+	}
+	elapsed := func() int { return (now - first) * scale }
+	traceTime := func() int { return first + elapsed() }
+	backend := &recordingBackend{Backend: sim, out: os.Stdout, now: traceTime}
+
+	count, fanCounts, prevTemps, prevFanSpeeds, err := initializeDevices(backend)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+
+	gameModeLock.Store(0)
+	ctl := newFanController(backend, config, count, fanCounts, prevTemps, prevFanSpeeds)
+	ctl.clock = func() time.Time { return start.Add(time.Duration(elapsed()) * time.Second) }
+
+	for i := 0; i < len(samples); {
+		now = samples[i].t
+		// Every row sharing this timestamp belongs to the same tick.
+		for ; i < len(samples) && samples[i].t == now; i++ {
+			s := samples[i]
+			sim.devices[s.gpu].SetTemperature(s.temp)
+			if s.watts >= 0 {
+				sim.devices[s.gpu].SetPower(s.watts)
+			}
+			if s.util >= 0 {
+				sim.devices[s.gpu].SetUtilization(s.util)
+			}
+			if s.mem >= 0 {
+				sim.devices[s.gpu].SetMemoryTemperature(s.mem)
+			}
+			if s.hotspot >= 0 {
+				sim.devices[s.gpu].SetHotspotTemperature(s.hotspot)
+			}
+			if s.gamemode != "" && (s.gamemode == "on") != (gameModeLock.Load() == 1) {
+				setGameMode(s.gamemode == "on")
+				fmt.Printf("t=%ds gamemode %s\n", traceTime(), s.gamemode)
+			}
+		}
+		// Like the daemon, the first update comes one time_to_update after start.
+		ctl.tickAt(float64(elapsed() + config.TimeToUpdate))
+	}
+	return 0
+}
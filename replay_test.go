@@ -0,0 +1,82 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestLoadTrace(t *testing.T) {
+	tests := []struct {
+		name    string
+		csv     string
+		times   []int
+		timed   bool
+		wantErr bool
+	}{
+		{"time column", "time_s,temp_c\n0,40\n2,41\n2,42\n7,43\n", []int{0, 2, 2, 7}, true, false},
+		{"row numbers without time", "temp\n40\n41\n", []int{0, 1}, false, false},
+		{"time goes backwards", "time,temp\n5,40\n3,41\n", nil, true, true},
+		{"no temperature column", "time,gpu\n0,0\n", nil, false, true},
+		{"no samples", "time,temp\n", nil, false, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			samples, timed, err := loadTrace(strings.NewReader(tt.csv))
+			if tt.wantErr {
+				if err == nil {
+					t.Fatal("expected an error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if timed != tt.timed {
+				t.Errorf("timed = %v, want %v", timed, tt.timed)
+			}
+			if len(samples) != len(tt.times) {
+				t.Fatalf("got %d samples, want %d", len(samples), len(tt.times))
+			}
+			for n, s := range samples {
+				if s.t != tt.times[n] {
+					t.Errorf("sample %d: t = %d, want %d", n, s.t, tt.times[n])
+				}
+			}
+		})
+	}
+}
+
+// The ramp limit measures time on the controller clock, which replay sets from
+// the trace rather than advancing by time_to_update.
+func TestTickAtUsesElapsedTime(t *testing.T) {
+	const config = `{
+		"time_to_update": 5,
+		"curve": true,
+		"ramp_up_per_second": 2,
+		"temperature_ranges": [
+			{"min_temperature": 0, "max_temperature": 40, "fan_speed": 30, "hysteresis": 3},
+			{"min_temperature": 40, "max_temperature": 200, "fan_speed": 80, "hysteresis": 3}
+		]
+	}`
+	tests := []struct {
+		name  string
+		times []float64
+		want  []int
+	}{
+		{"one second apart", []float64{1, 2, 3}, []int{32, 34, 36}},
+		{"uneven gaps", []float64{1, 4, 14}, []int{32, 38, 58}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sim := newTestSim(t, 1, 1, []int{45})
+			c := newTestController(t, config, sim)
+			sim.devices[0].SetTemperature(70)
+			for n, now := range tt.times {
+				c.tickAt(now)
+				if got, _ := sim.devices[0].FanSpeed(0); got != tt.want[n] {
+					t.Fatalf("at %gs: fan %d%%, want %d%%", now, got, tt.want[n])
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,385 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/NVIDIA/go-nvml/pkg/nvml"
+)
+
+// ---------- Time-of-day schedules (profiles and quiet-hours caps) ----------
+
+// ScheduleConfig switches the control settings to a profile and/or caps the
+// fan speed during a daily time window.
+type ScheduleConfig struct {
+	Name     string   `json:"name"`
+	Days     []string `json:"days,omitempty"`      // "mon".."sun" or ranges like "mon-fri"; empty = every day
+	Start    string   `json:"start"`               // "HH:MM", local time
+	End      string   `json:"end"`                 // "HH:MM"; at or before start = ends the next day
+	Profile  string   `json:"profile,omitempty"`   // profiles entry to run while active
+	MaxSpeed *int     `json:"max_speed,omitempty"` // cap on normal-mode fan speeds; the failsafes are not capped
+}
+
+// ProfileConfig is a named set of control settings a schedule can switch to.
+// Its keys mean the same as in a devices section and replace those of every device.
+type ProfileConfig struct {
+	Curve             *bool              `json:"curve,omitempty"`
+	TemperatureRanges []TemperatureRange `json:"temperature_ranges,omitempty"`
+	CurvePoints       *CurveConfig       `json:"curve_points,omitempty"`
+	Interpolation     *string            `json:"interpolation,omitempty"`
+	FloorTemperature  *int               `json:"floor_temperature,omitempty"`
+	FloorHysteresis   *int               `json:"floor_hysteresis,omitempty"`
+	Hysteresis        *int               `json:"hysteresis,omitempty"`
+	PID               *PIDConfig         `json:"pid,omitempty"`
+	RampUpPerSecond   *float64           `json:"ramp_up_per_second,omitempty"`
+	RampDownPerSecond *float64           `json:"ramp_down_per_second,omitempty"`
+}
+
+func (p ProfileConfig) deviceConfig() DeviceConfig {
+	return DeviceConfig{
+		Curve:             p.Curve,
+		TemperatureRanges: p.TemperatureRanges,
+		CurvePoints:       p.CurvePoints,
+		Interpolation:     p.Interpolation,
+		FloorTemperature:  p.FloorTemperature,
+		FloorHysteresis:   p.FloorHysteresis,
+		Hysteresis:        p.Hysteresis,
+		PID:               p.PID,
+		RampUpPerSecond:   p.RampUpPerSecond,
+		RampDownPerSecond: p.RampDownPerSecond,
+	}
+}
+
+var weekdayNames = []string{"sun", "mon", "tue", "wed", "thu", "fri", "sat"}
+
+// scheduleWindow is a parsed schedule.
+type scheduleWindow struct {
+	cfg        ScheduleConfig
+	days       [7]bool // by time.Weekday; for overnight windows, the day it starts
+	start, end int     // minutes after midnight
+}
+
+func parseClock(s string) (int, error) {
+	h, m, ok := strings.Cut(strings.TrimSpace(s), ":")
+	hh, err1 := strconv.Atoi(h)
+	mm, err2 := strconv.Atoi(m)
+	if !ok || err1 != nil || err2 != nil || hh < 0 || hh > 23 || mm < 0 || mm > 59 {
+		return 0, fmt.Errorf("invalid time %q (expected HH:MM)", s)
+	}
+	return hh*60 + mm, nil
+}
+
+func parseWeekday(s string) (int, error) {
+	for d, name := range weekdayNames {
+		if strings.EqualFold(strings.TrimSpace(s), name) {
+			return d, nil
+		}
+	}
+	return 0, fmt.Errorf("unknown day %q (expected mon|tue|wed|thu|fri|sat|sun)", s)
+}
+
+func parseScheduleWindow(sc ScheduleConfig) (scheduleWindow, error) {
+	w := scheduleWindow{cfg: sc}
+	var err error
+	if w.start, err = parseClock(sc.Start); err != nil {
+		return w, fmt.Errorf("start: %w", err)
+	}
+	if w.end, err = parseClock(sc.End); err != nil {
+		return w, fmt.Errorf("end: %w", err)
+	}
+	if len(sc.Days) == 0 {
+		for d := range w.days {
+			w.days[d] = true
+		}
+	}
+	for _, entry := range sc.Days {
+		from, to, isRange := strings.Cut(entry, "-")
+		first, err := parseWeekday(from)
+		if err != nil {
+			return w, err
+		}
+		last := first
+		if isRange {
+			if last, err = parseWeekday(to); err != nil {
+				return w, err
+			}
+		}
+		// Ranges may wrap past Sunday, e.g. "fri-mon".
+		for d := first; ; d = (d + 1) % 7 {
+			w.days[d] = true
+			if d == last {
+				break
+			}
+		}
+	}
+	if sc.MaxSpeed != nil && (*sc.MaxSpeed < 1 || *sc.MaxSpeed > 100) {
+		return w, fmt.Errorf("max_speed must be 1..100 (got %d)", *sc.MaxSpeed)
+	}
+	if sc.Profile == "" && sc.MaxSpeed == nil {
+		return w, fmt.Errorf("set profile and/or max_speed")
+	}
+	return w, nil
+}
+
+// active reports whether the window covers t. A window that crosses midnight
+// belongs to the day it starts on.
+func (w scheduleWindow) active(t time.Time) bool {
+	m := t.Hour()*60 + t.Minute()
+	today := w.days[t.Weekday()]
+	yesterday := w.days[(t.Weekday()+6)%7]
+	switch {
+	case w.start < w.end:
+		return today && m >= w.start && m < w.end
+	case w.start == w.end:
+		return today
+	default:
+		return (today && m >= w.start) || (yesterday && m < w.end)
+	}
+}
+
+func (w scheduleWindow) String() string {
+	days := "every day"
+	if len(w.cfg.Days) > 0 {
+		days = strings.Join(w.cfg.Days, ",")
+	}
+	out := fmt.Sprintf("%02d:%02d-%02d:%02d %s", w.start/60, w.start%60, w.end/60, w.end%60, days)
+	if w.cfg.Profile != "" {
+		out += ", profile " + w.cfg.Profile
+	}
+	if w.cfg.MaxSpeed != nil {
+		out += fmt.Sprintf(", max %d%%", *w.cfg.MaxSpeed)
+	}
+	return out
+}
+
+// parseScheduleWindows parses the schedules section in order.
+func parseScheduleWindows(list []ScheduleConfig) ([]scheduleWindow, error) {
+	out := make([]scheduleWindow, 0, len(list))
+	for n, sc := range list {
+		w, err := parseScheduleWindow(sc)
+		if err != nil {
+			return nil, fmt.Errorf("schedules[%d] %q: %w", n, sc.Name, err)
+		}
+		out = append(out, w)
+	}
+	return out, nil
+}
+
+// activeScheduleAt returns the index of the first window covering t, or -1.
+func activeScheduleAt(windows []scheduleWindow, t time.Time) int {
+	for k, w := range windows {
+		if w.active(t) {
+			return k
+		}
+	}
+	return -1
+}
+
+// describeSchedules names the schedule active at t, for status output.
+func describeSchedules(list []ScheduleConfig, t time.Time) string {
+	windows, err := parseScheduleWindows(list)
+	if err != nil {
+		return fmt.Sprintf("invalid (%v)", err)
+	}
+	k := activeScheduleAt(windows, t)
+	if k < 0 {
+		return fmt.Sprintf("none active at %s (%d configured)", t.Format("Mon 15:04"), len(windows))
+	}
+	return fmt.Sprintf("%s active at %s (%s)", windows[k].cfg.Name, t.Format("Mon 15:04"), windows[k])
+}
+
+// validateSchedules checks the schedules and profiles sections.
+func validateSchedules(config Config) error {
+	seen := map[string]bool{}
+	for n, sc := range config.Schedules {
+		if sc.Name == "" {
+			return fmt.Errorf("schedules[%d]: name must be set", n)
+		}
+		if seen[sc.Name] {
+			return fmt.Errorf("schedules[%d]: duplicate schedule name %q", n, sc.Name)
+		}
+		seen[sc.Name] = true
+		if _, ok := config.Profiles[sc.Profile]; sc.Profile != "" && !ok {
+			return fmt.Errorf("schedule %q: no profiles entry named %q", sc.Name, sc.Profile)
+		}
+	}
+	if _, err := parseScheduleWindows(config.Schedules); err != nil {
+		return err
+	}
+	for name, p := range config.Profiles {
+		if p.Interpolation != nil {
+			if err := validInterpolation(*p.Interpolation); err != nil {
+				return fmt.Errorf("profiles[%q]: %w", name, err)
+			}
+		}
+		if p.CurvePoints != nil {
+			if err := p.CurvePoints.validate(); err != nil {
+				return fmt.Errorf("profiles[%q].curve_points: %w", name, err)
+			}
+		}
+	}
+	return nil
+}
+
+// resolveSchedules parses the schedules and resolves every device's settings
+// under each profile they use, so a switch is just a swap.
+func (c *fanController) resolveSchedules(config Config) {
+	c.clock = time.Now
+	c.schedule = -1
+	windows, err := parseScheduleWindows(config.Schedules)
+	if err != nil {
+		log.Printf("WARN: Invalid schedules: %v. Schedules disabled.", err)
+		return
+	}
+	c.schedules = windows
+	if len(windows) == 0 {
+		return
+	}
+
+	c.baseSettings = append([]deviceSettings(nil), c.settings...)
+	c.profileSettings = map[string][]deviceSettings{}
+	for _, w := range windows {
+		name := w.cfg.Profile
+		if name == "" || c.profileSettings[name] != nil {
+			continue
+		}
+		p, ok := config.Profiles[name]
+		if !ok {
+			log.Printf("WARN: Schedule %q: no profiles entry named %q; it only applies its cap.", w.cfg.Name, name)
+			continue
+		}
+		list := make([]deviceSettings, c.count)
+		for i, s := range c.baseSettings {
+			s.apply(p.deviceConfig())
+			s.resolve(fmt.Sprintf("%s (profile %s): ", s.label, name))
+			list[i] = s
+		}
+		c.profileSettings[name] = list
+	}
+	for _, w := range windows {
+		log.Printf("INFO: Schedule %q: %s.", w.cfg.Name, w)
+	}
+}
+
+// updateSchedule switches to the schedule covering the controller clock's
+// current time, logging every transition. The curve is re-applied right away
+// so a new profile or cap takes effect without waiting for the temperature.
+func (c *fanController) updateSchedule() {
+	if len(c.schedules) == 0 {
+		return
+	}
+	now := c.clock()
+	active := activeScheduleAt(c.schedules, now)
+	if active == c.schedule {
+		return
+	}
+	if active < 0 {
+		log.Printf("INFO: Schedule %q ended (%s); back to the configured settings.", c.schedules[c.schedule].cfg.Name, now.Format("Mon 15:04"))
+	} else {
+		log.Printf("INFO: Schedule %q active (%s): %s.", c.schedules[active].cfg.Name, now.Format("Mon 15:04"), c.schedules[active])
+	}
+	c.schedule = active
+
+	profile := ""
+	if active >= 0 {
+		profile = c.schedules[active].cfg.Profile
+	}
+	if profile != c.profile {
+		c.profile = profile
+		for i := range c.settings {
+			if list := c.profileSettings[profile]; list != nil {
+				c.useSettings(i, list[i])
+			} else {
+				c.useSettings(i, c.baseSettings[i])
+			}
+		}
+	}
+	for i := range c.resync {
+		c.resync[i] = true
+	}
+}
+
+// useSettings switches device i to s and re-resolves the state newFanController
+// derives from the settings: the floor side, the fan speed range and the filter.
+func (c *fanController) useSettings(i int, s deviceSettings) {
+	old := c.settings[i]
+	c.settings[i] = s
+	c.pid[i].reset()
+
+	dev, ret := c.backend.DeviceByIndex(i)
+	if ret != nvml.SUCCESS {
+		dev = nil
+	}
+	c.limits[i] = c.resolveFanLimits(i, dev)
+
+	// An unchanged filter keeps its history.
+	if s.filter != old.filter {
+		f, err := newTempFilter(s.filter)
+		if err != nil {
+			log.Printf("WARN: %s: invalid temperature_filter: %v. Using raw temperatures.", s.label, err)
+			f, _ = newTempFilter(nil)
+		}
+		c.filters[i] = f
+	}
+
+	// The emergency and read failsafes own the fans and set inAuto themselves.
+	if c.inEmergency[i] || c.degraded[i] {
+		return
+	}
+	// Same rule as at startup, except that the gamemode lock keeps MANUAL
+	// fans out of AUTO.
+	wasAuto := c.inAuto[i]
+	c.inAuto[i] = c.prevTemps[i] < s.prof.floorEndTemp && (wasAuto || gameModeLock.Load() == 0)
+	if wasAuto && !c.inAuto[i] && s.ramped() && dev != nil {
+		// Ramp from whatever speed the driver was running in AUTO.
+		c.refreshFanSpeeds(i, dev)
+	}
+}
+
+// speedCap is the active schedule's max_speed, or 100.
+func (c *fanController) speedCap() int {
+	if c.schedule < 0 || c.schedules[c.schedule].cfg.MaxSpeed == nil {
+		return 100
+	}
+	return *c.schedules[c.schedule].cfg.MaxSpeed
+}
+
+// capSpeed limits a normal-mode speed to the active schedule's cap.
+func (c *fanController) capSpeed(speed int) int {
+	if limit := c.speedCap(); speed > limit {
+		return limit
+	}
+	return speed
+}
+
+// capDetail is the cap part of the update log line when it lowered a target, or "".
+func (c *fanController) capDetail(target int) string {
+	if target <= c.speedCap() {
+		return ""
+	}
+	return fmt.Sprintf(", Cap=%d%% (schedule %s)", c.speedCap(), c.schedules[c.schedule].cfg.Name)
+}
+
+// scheduleStatus names the active schedule for STATUS=, or "".
+func (c *fanController) scheduleStatus() string {
+	if c.schedule < 0 {
+		return ""
+	}
+	return "schedule " + c.schedules[c.schedule].cfg.Name
+}
+
+// parseClockFlag parses the -clock flag of simulate and replay: the local
+// wall-clock time the run starts at, so schedules can be tried out. "" is now.
+func parseClockFlag(s string) (time.Time, error) {
+	if s == "" {
+		return time.Now(), nil
+	}
+	t, err := time.ParseInLocation("2006-01-02T15:04", s, time.Local)
+	if err != nil {
+		return t, fmt.Errorf("invalid -clock %q (expected YYYY-MM-DDTHH:MM)", s)
+	}
+	return t, nil
+}
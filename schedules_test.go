@@ -0,0 +1,130 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/NVIDIA/go-nvml/pkg/nvml"
+)
+
+// 2024-01-01 is a Monday.
+func testTime(day, hour, minute int) time.Time {
+	return time.Date(2024, 1, day, hour, minute, 0, 0, time.Local)
+}
+
+func TestScheduleWindowActive(t *testing.T) {
+	maxSpeed := 40
+	tests := []struct {
+		name string
+		cfg  ScheduleConfig
+		at   time.Time
+		want bool
+	}{
+		{"inside", ScheduleConfig{Start: "09:00", End: "17:00"}, testTime(1, 12, 0), true},
+		{"at start", ScheduleConfig{Start: "09:00", End: "17:00"}, testTime(1, 9, 0), true},
+		{"at end", ScheduleConfig{Start: "09:00", End: "17:00"}, testTime(1, 17, 0), false},
+		{"overnight evening", ScheduleConfig{Start: "22:00", End: "07:00"}, testTime(1, 23, 30), true},
+		{"overnight morning", ScheduleConfig{Start: "22:00", End: "07:00"}, testTime(2, 6, 59), true},
+		{"overnight daytime", ScheduleConfig{Start: "22:00", End: "07:00"}, testTime(2, 12, 0), false},
+		{"weekdays on monday", ScheduleConfig{Days: []string{"mon-fri"}, Start: "09:00", End: "17:00"}, testTime(1, 10, 0), true},
+		{"weekdays on sunday", ScheduleConfig{Days: []string{"mon-fri"}, Start: "09:00", End: "17:00"}, testTime(7, 10, 0), false},
+		{"range wrapping past sunday", ScheduleConfig{Days: []string{"fri-mon"}, Start: "09:00", End: "17:00"}, testTime(7, 10, 0), true},
+		{"overnight belongs to its start day", ScheduleConfig{Days: []string{"fri"}, Start: "22:00", End: "07:00"}, testTime(6, 6, 0), true},
+		{"overnight not started the day before", ScheduleConfig{Days: []string{"fri"}, Start: "22:00", End: "07:00"}, testTime(5, 6, 0), false},
+		{"start equals end is all day", ScheduleConfig{Days: []string{"sat"}, Start: "00:00", End: "00:00"}, testTime(6, 15, 0), true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.cfg.MaxSpeed = &maxSpeed
+			w, err := parseScheduleWindow(tt.cfg)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := w.active(tt.at); got != tt.want {
+				t.Fatalf("active(%s)=%v, want %v", tt.at.Format("Mon 15:04"), got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseScheduleWindowRejects(t *testing.T) {
+	zero, over := 0, 101
+	tests := []struct {
+		name string
+		cfg  ScheduleConfig
+	}{
+		{"bad start", ScheduleConfig{Start: "25:00", End: "07:00", Profile: "quiet"}},
+		{"bad end", ScheduleConfig{Start: "22:00", End: "7", Profile: "quiet"}},
+		{"unknown day", ScheduleConfig{Days: []string{"mon-fry"}, Start: "22:00", End: "07:00", Profile: "quiet"}},
+		{"max_speed 0", ScheduleConfig{Start: "22:00", End: "07:00", MaxSpeed: &zero}},
+		{"max_speed above 100", ScheduleConfig{Start: "22:00", End: "07:00", MaxSpeed: &over}},
+		{"neither profile nor max_speed", ScheduleConfig{Start: "22:00", End: "07:00"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := parseScheduleWindow(tt.cfg); err == nil {
+				t.Fatal("expected an error")
+			}
+		})
+	}
+}
+
+func TestScheduleSwitches(t *testing.T) {
+	const config = `{
+		"time_to_update": 5,
+		"curve": true,
+		"floor_temperature": 40,
+		"floor_hysteresis": 5,
+		"temperature_ranges": [
+			{"min_temperature": 0, "max_temperature": 40, "fan_speed": 30, "hysteresis": 1},
+			{"min_temperature": 40, "max_temperature": 100, "fan_speed": 90, "hysteresis": 1}
+		],
+		"schedules": [
+			{"name": "night", "start": "22:00", "end": "07:00", "profile": "quiet"},
+			{"name": "lunch", "start": "12:00", "end": "13:00", "max_speed": 50}
+		],
+		"profiles": {
+			"quiet": {"floor_temperature": 50}
+		}
+	}`
+	tests := []struct {
+		name     string
+		at       time.Time
+		wantAuto bool
+		want     int // fan speed when in MANUAL
+	}{
+		{"no schedule", testTime(1, 10, 0), false, 90},
+		{"cap", testTime(1, 12, 30), false, 50},
+		// 48°C is inside the new floor's deadband: the switch itself decides AUTO.
+		{"profile with a higher floor", testTime(1, 23, 0), true, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sim := newTestSim(t, 1, 1, []int{44})
+			c := newTestController(t, config, sim)
+			now := testTime(1, 9, 0)
+			c.clock = func() time.Time { return now }
+
+			sim.devices[0].SetTemperature(48)
+			c.tick() // MANUAL on the configured curve
+			if c.inAuto[0] || sim.devices[0].Policy(0) != nvml.FAN_POLICY_MANUAL {
+				t.Fatal("fans not in MANUAL above the configured floor")
+			}
+
+			now = tt.at
+			c.tick()
+			if c.inAuto[0] != tt.wantAuto {
+				t.Fatalf("inAuto=%v, want %v", c.inAuto[0], tt.wantAuto)
+			}
+			if tt.wantAuto {
+				if sim.devices[0].Policy(0) == nvml.FAN_POLICY_MANUAL {
+					t.Fatal("fans still in MANUAL below the profile's floor")
+				}
+				return
+			}
+			if got, _ := sim.devices[0].FanSpeed(0); got != tt.want {
+				t.Fatalf("fan at %d%%, want %d%%", got, tt.want)
+			}
+		})
+	}
+}
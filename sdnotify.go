@@ -0,0 +1,100 @@
+package main
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ---------- systemd notify protocol (no libsystemd) ----------
+
+// sdNotify sends one datagram to $NOTIFY_SOCKET. It returns false without an
+// error when the daemon was not started by systemd with Type=notify.
+func sdNotify(state string) (bool, error) {
+	path := os.Getenv("NOTIFY_SOCKET")
+	if path == "" {
+		return false, nil
+	}
+	if strings.HasPrefix(path, "@") {
+		path = "\x00" + path[1:] // abstract namespace
+	}
+	conn, err := net.DialUnix("unixgram", nil, &net.UnixAddr{Name: path, Net: "unixgram"})
+	if err != nil {
+		return false, err
+	}
+	defer conn.Close()
+	if _, err := conn.Write([]byte(state)); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// sdWatchdogInterval returns how often to send WATCHDOG=1 (half of
+// $WATCHDOG_USEC), or 0 when the watchdog is not enabled for this process.
+func sdWatchdogInterval() (time.Duration, error) {
+	usecStr := os.Getenv("WATCHDOG_USEC")
+	if usecStr == "" {
+		return 0, nil
+	}
+	if pidStr := os.Getenv("WATCHDOG_PID"); pidStr != "" {
+		pid, err := strconv.Atoi(pidStr)
+		if err != nil {
+			return 0, fmt.Errorf("invalid WATCHDOG_PID %q", pidStr)
+		}
+		if pid != os.Getpid() {
+			return 0, nil
+		}
+	}
+	usec, err := strconv.ParseInt(usecStr, 10, 64)
+	if err != nil || usec <= 0 {
+		return 0, fmt.Errorf("invalid WATCHDOG_USEC %q", usecStr)
+	}
+	return time.Duration(usec) * time.Microsecond / 2, nil
+}
+
+// statusLine summarizes every controlled device for STATUS=.
+func (c *fanController) statusLine() string {
+	parts := make([]string, 0, c.count)
+	for i := 0; i < c.count; i++ {
+		if c.fanCounts[i] == 0 {
+			continue
+		}
+		s := c.settings[i]
+		mode := "step"
+		if s.usePID {
+			mode = "pid"
+		} else if s.useCurve {
+			mode = "curve"
+		}
+
+		var state string
+		switch {
+		case c.degraded[i]:
+			state = "DEGRADED"
+		case c.inEmergency[i]:
+			state = "EMERGENCY 100%"
+		case (s.useCurve || s.usePID) && c.inAuto[i] && !s.prof.floorManual:
+			state = "AUTO"
+		default:
+			state = fmt.Sprintf("%d%%", c.prevFanSpeeds[i][0])
+			if c.spinningDown(i) {
+				state += " spin-down"
+			}
+		}
+		parts = append(parts, fmt.Sprintf("%s %d°C %s %s", s.label, c.prevTemps[i], state, mode))
+	}
+	out := strings.Join(parts, "; ")
+	if zones := c.zoneStatus(); zones != "" {
+		out += " | " + zones
+	}
+	if schedule := c.scheduleStatus(); schedule != "" {
+		out += " | " + schedule
+	}
+	if gameModeLock.Load() != 0 {
+		out += " | gamemode on"
+	}
+	return out
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// listenNotify starts a unixgram listener standing in for systemd's notify socket.
+func listenNotify(t *testing.T, name string) *net.UnixConn {
+	t.Helper()
+	conn, err := net.ListenUnixgram("unixgram", &net.UnixAddr{Name: name, Net: "unixgram"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return conn
+}
+
+func TestSdNotify(t *testing.T) {
+	dir := t.TempDir()
+	abstract := fmt.Sprintf("nvidia-fan-control-test-%d", os.Getpid())
+	tests := []struct {
+		name    string
+		socket  string // NOTIFY_SOCKET
+		listen  string // listener address, "" = none
+		wantOK  bool
+		wantErr bool
+	}{
+		{name: "not under systemd", socket: ""},
+		{name: "path socket", socket: filepath.Join(dir, "notify"), listen: filepath.Join(dir, "notify"), wantOK: true},
+		{name: "abstract socket", socket: "@" + abstract, listen: "\x00" + abstract, wantOK: true},
+		{name: "nobody listening", socket: filepath.Join(dir, "missing"), wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("NOTIFY_SOCKET", tt.socket)
+			var conn *net.UnixConn
+			if tt.listen != "" {
+				conn = listenNotify(t, tt.listen)
+			}
+			const state = "READY=1\nSTATUS=GPU 0 55°C 40% curve"
+			ok, err := sdNotify(state)
+			if ok != tt.wantOK || (err != nil) != tt.wantErr {
+				t.Fatalf("sdNotify=%v, %v; want %v, error %v", ok, err, tt.wantOK, tt.wantErr)
+			}
+			if conn == nil {
+				return
+			}
+			buf := make([]byte, 256)
+			conn.SetReadDeadline(time.Now().Add(time.Second))
+			n, err := conn.Read(buf)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := string(buf[:n]); got != state {
+				t.Fatalf("received %q, want %q", got, state)
+			}
+		})
+	}
+}
+
+func TestSdWatchdogInterval(t *testing.T) {
+	self, other := fmt.Sprint(os.Getpid()), fmt.Sprint(os.Getpid()+1)
+	tests := []struct {
+		name    string
+		usec    string // WATCHDOG_USEC
+		pid     string // WATCHDOG_PID
+		want    time.Duration
+		wantErr bool
+	}{
+		{name: "disabled", usec: ""},
+		{name: "half the timeout", usec: "10000000", want: 5 * time.Second},
+		{name: "for this process", usec: "4000000", pid: self, want: 2 * time.Second},
+		{name: "for another process", usec: "4000000", pid: other},
+		{name: "invalid timeout", usec: "soon", wantErr: true},
+		{name: "zero timeout", usec: "0", wantErr: true},
+		{name: "invalid pid", usec: "4000000", pid: "self", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("WATCHDOG_USEC", tt.usec)
+			t.Setenv("WATCHDOG_PID", tt.pid)
+			got, err := sdWatchdogInterval()
+			if got != tt.want || (err != nil) != tt.wantErr {
+				t.Fatalf("sdWatchdogInterval=%v, %v; want %v, error %v", got, err, tt.want, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,194 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"path"
+	"strconv"
+	"strings"
+
+	"github.com/NVIDIA/go-nvml/pkg/nvml"
+)
+
+// ---------- GPU selection by index, UUID, PCI bus ID or name ----------
+
+// GPUSelector picks a GPU by:
+//
+//	"0"                    NVML index (a bare JSON number works too)
+//	"uuid:GPU-8a1b..."     UUID (the "GPU-" prefix is optional)
+//	"pci:0000:65:00.0"     PCI bus ID (domain may be 4 or 8 hex digits, or omitted)
+//	"name:4090"            case-insensitive substring of the product name
+//	"name:*RTX*Blower*"    or a glob pattern over the whole name
+//
+// Any other non-numeric value is treated as a name match. Indices change when
+// cards are added or the driver enumerates differently; UUID and PCI do not.
+type GPUSelector string
+
+func (s *GPUSelector) UnmarshalJSON(data []byte) error {
+	var n int
+	if err := json.Unmarshal(data, &n); err == nil {
+		*s = GPUSelector(strconv.Itoa(n))
+		return nil
+	}
+	var str string
+	if err := json.Unmarshal(data, &str); err != nil {
+		return fmt.Errorf("gpu selector must be a number or string: %s", data)
+	}
+	*s = GPUSelector(str)
+	return nil
+}
+
+// deviceIdentity is what a GPU reports about itself; empty fields are unknown.
+type deviceIdentity struct {
+	uuid string
+	pci  string
+	name string
+}
+
+func (id deviceIdentity) String() string {
+	parts := []string{}
+	if id.name != "" {
+		parts = append(parts, id.name)
+	}
+	if id.uuid != "" {
+		parts = append(parts, "uuid="+id.uuid)
+	}
+	if id.pci != "" {
+		parts = append(parts, "pci="+id.pci)
+	}
+	if len(parts) == 0 {
+		return "identity unknown"
+	}
+	return strings.Join(parts, ", ")
+}
+
+func identifyDevice(dev Device) deviceIdentity {
+	var id deviceIdentity
+	if v, ret := dev.UUID(); ret == nvml.SUCCESS {
+		id.uuid = v
+	}
+	if v, ret := dev.PCIBusID(); ret == nvml.SUCCESS {
+		id.pci = v
+	}
+	if v, ret := dev.Name(); ret == nvml.SUCCESS {
+		id.name = v
+	}
+	return id
+}
+
+// normalizePCIBusID turns "0000:65:00.0", "00000000:65:00.0" and "65:00.0"
+// into one comparable form.
+func normalizePCIBusID(s string) (string, bool) {
+	s = strings.ToLower(strings.TrimSpace(s))
+	parts := strings.Split(s, ":")
+	if len(parts) == 2 {
+		parts = append([]string{"0"}, parts...)
+	}
+	if len(parts) != 3 {
+		return "", false
+	}
+	devFn := strings.Split(parts[2], ".")
+	if len(devFn) != 2 {
+		return "", false
+	}
+	var nums [4]uint64
+	for i, p := range []string{parts[0], parts[1], devFn[0], devFn[1]} {
+		n, err := strconv.ParseUint(p, 16, 32)
+		if err != nil {
+			return "", false
+		}
+		nums[i] = n
+	}
+	return fmt.Sprintf("%08x:%02x:%02x.%x", nums[0], nums[1], nums[2], nums[3]), true
+}
+
+// matches reports whether the selector picks the device with this index/identity.
+func (s GPUSelector) matches(idx int, id deviceIdentity) (bool, error) {
+	str := strings.TrimSpace(string(s))
+	kind, value, ok := strings.Cut(str, ":")
+	if !ok {
+		if n, err := strconv.Atoi(str); err == nil {
+			return n == idx, nil
+		}
+		kind, value = "name", str
+	}
+
+	switch strings.ToLower(kind) {
+	case "uuid":
+		want := strings.TrimPrefix(strings.ToLower(value), "gpu-")
+		have := strings.TrimPrefix(strings.ToLower(id.uuid), "gpu-")
+		return have != "" && have == want, nil
+	case "pci":
+		want, ok := normalizePCIBusID(value)
+		if !ok {
+			return false, fmt.Errorf("invalid PCI bus ID %q (expected DOMAIN:BUS:DEVICE.FUNCTION)", value)
+		}
+		have, ok := normalizePCIBusID(id.pci)
+		return ok && have == want, nil
+	case "name":
+		if id.name == "" {
+			return false, nil
+		}
+		name, pattern := strings.ToLower(id.name), strings.ToLower(value)
+		if strings.ContainsAny(pattern, "*?[") {
+			ok, err := path.Match(pattern, name)
+			if err != nil {
+				return false, fmt.Errorf("invalid name pattern %q: %w", value, err)
+			}
+			return ok, nil
+		}
+		return strings.Contains(name, pattern), nil
+	case "index":
+		n, err := strconv.Atoi(value)
+		if err != nil {
+			return false, fmt.Errorf("invalid GPU index %q", value)
+		}
+		return n == idx, nil
+	default:
+		return false, fmt.Errorf("unknown GPU selector %q (expected N, uuid:, pci: or name:)", str)
+	}
+}
+
+// resolveGPU finds the single device a selector refers to.
+func resolveGPU(backend Backend, sel GPUSelector) (int, error) {
+	count, ret := backend.DeviceCount()
+	if ret != nvml.SUCCESS {
+		return -1, fmt.Errorf("unable to get NVIDIA device count: %v", nvml.ErrorString(ret))
+	}
+
+	// Plain indices keep the original range error.
+	if n, err := strconv.Atoi(strings.TrimSpace(string(sel))); err == nil {
+		if n < 0 || n >= count {
+			return -1, fmt.Errorf("invalid GPU index %d (found %d device(s), valid range: 0..%d)", n, count, count-1)
+		}
+		return n, nil
+	}
+
+	found := -1
+	for i := 0; i < count; i++ {
+		dev, ret := backend.DeviceByIndex(i)
+		if ret != nvml.SUCCESS {
+			continue
+		}
+		ok, err := sel.matches(i, identifyDevice(dev))
+		if err != nil {
+			return -1, err
+		}
+		if !ok {
+			continue
+		}
+		if found >= 0 {
+			return -1, fmt.Errorf("GPU selector %q is ambiguous: matches devices %d and %d", sel, found, i)
+		}
+		found = i
+	}
+	if found < 0 {
+		return -1, fmt.Errorf("no GPU matches %q (found %d device(s))", sel, count)
+	}
+
+	if dev, ret := backend.DeviceByIndex(found); ret == nvml.SUCCESS {
+		log.Printf("INFO: GPU selector %q resolved to device %d (%s).", sel, found, identifyDevice(dev))
+	}
+	return found, nil
+}
@@ -0,0 +1,135 @@
+package main
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestNormalizePCIBusID(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+		ok   bool
+	}{
+		{"0000:65:00.0", "00000000:65:00.0", true},
+		{"00000000:65:00.0", "00000000:65:00.0", true},
+		{"65:00.0", "00000000:65:00.0", true},
+		{"0000:0A:00.1", "00000000:0a:00.1", true},
+		{" 0000:0a:00.1 ", "00000000:0a:00.1", true},
+		{"0001:65:00.0", "00000001:65:00.0", true},
+		{"65:00", "", false},
+		{"0000:65:00:0", "", false},
+		{"0000:zz:00.0", "", false},
+		{"", "", false},
+	}
+	for _, tt := range tests {
+		got, ok := normalizePCIBusID(tt.in)
+		if got != tt.want || ok != tt.ok {
+			t.Errorf("normalizePCIBusID(%q)=%q, %v; want %q, %v", tt.in, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestGPUSelectorMatches(t *testing.T) {
+	id := deviceIdentity{
+		uuid: "GPU-8a1b2c3d-0000-1111-2222-333344445555",
+		pci:  "00000000:65:00.0",
+		name: "NVIDIA GeForce RTX 4090",
+	}
+	tests := []struct {
+		sel     GPUSelector
+		idx     int
+		want    bool
+		wantErr bool
+	}{
+		{sel: "1", idx: 1, want: true},
+		{sel: "1", idx: 0, want: false},
+		{sel: " 1 ", idx: 1, want: true},
+		{sel: "index:1", idx: 1, want: true},
+		{sel: "index:x", wantErr: true},
+		{sel: "uuid:GPU-8a1b2c3d-0000-1111-2222-333344445555", want: true},
+		{sel: "uuid:8a1b2c3d-0000-1111-2222-333344445555", want: true},
+		{sel: "UUID:gpu-8A1B2C3D-0000-1111-2222-333344445555", want: true},
+		{sel: "uuid:GPU-8a1b2c3d", want: false},
+		{sel: "pci:0000:65:00.0", want: true},
+		{sel: "pci:65:00.0", want: true},
+		{sel: "pci:00000000:65:00.0", want: true},
+		{sel: "PCI:0000:65:00.0", want: true},
+		{sel: "pci:0000:66:00.0", want: false},
+		{sel: "pci:65", wantErr: true},
+		{sel: "name:4090", want: true},
+		{sel: "name:rtx 4090", want: true},
+		{sel: "name:4080", want: false},
+		{sel: "name:*geforce*4090", want: true},
+		{sel: "name:*4080*", want: false},
+		{sel: "name:[", wantErr: true},
+		{sel: "GeForce", want: true},
+		{sel: "serial:123", wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := tt.sel.matches(tt.idx, id)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%q: err=%v, want error %v", tt.sel, err, tt.wantErr)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("%q at index %d: %v, want %v", tt.sel, tt.idx, got, tt.want)
+		}
+	}
+}
+
+func TestGPUSelectorUnknownIdentity(t *testing.T) {
+	for _, sel := range []GPUSelector{"uuid:GPU-1", "pci:0000:65:00.0", "name:GPU"} {
+		if ok, err := sel.matches(0, deviceIdentity{}); ok || err != nil {
+			t.Errorf("%q matched a device that reports nothing (err=%v)", sel, err)
+		}
+	}
+}
+
+func TestGPUSelectorUnmarshalJSON(t *testing.T) {
+	tests := []struct {
+		in   string
+		want GPUSelector
+	}{
+		{`2`, "2"},
+		{`"2"`, "2"},
+		{`"pci:65:00.0"`, "pci:65:00.0"},
+	}
+	for _, tt := range tests {
+		var sel GPUSelector
+		if err := json.Unmarshal([]byte(tt.in), &sel); err != nil || sel != tt.want {
+			t.Errorf("%s: %q, %v; want %q", tt.in, sel, err, tt.want)
+		}
+	}
+	var sel GPUSelector
+	if err := json.Unmarshal([]byte(`[1]`), &sel); err == nil {
+		t.Error("an array was accepted as a GPU selector")
+	}
+}
+
+func TestResolveGPU(t *testing.T) {
+	sim := newTestSim(t, 3, 1, nil)
+	tests := []struct {
+		sel     GPUSelector
+		want    int
+		wantErr bool
+	}{
+		{sel: "2", want: 2},
+		{sel: "3", wantErr: true},
+		{sel: "uuid:GPU-00000000-0000-0000-0000-000000000001", want: 1},
+		{sel: "pci:02:00.0", want: 1},
+		{sel: "pci:0000:03:00.0", want: 2},
+		{sel: "pci:0000:09:00.0", wantErr: true},
+		{sel: "name:simulated", wantErr: true}, // ambiguous: every GPU matches
+	}
+	for _, tt := range tests {
+		got, err := resolveGPU(sim, tt.sel)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%q: err=%v, want error %v", tt.sel, err, tt.wantErr)
+			continue
+		}
+		if !tt.wantErr && got != tt.want {
+			t.Errorf("%q resolved to %d, want %d", tt.sel, got, tt.want)
+		}
+	}
+}
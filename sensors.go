@@ -0,0 +1,333 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/NVIDIA/go-nvml/pkg/nvml"
+)
+
+// ---------- Temperature inputs (core, memory, hotspot, composites) ----------
+
+// sensorAliases maps the names accepted in "sensor" to the canonical ones.
+var sensorAliases = map[string]string{
+	"core":    "core",
+	"gpu":     "core",
+	"mem":     "mem",
+	"memory":  "mem",
+	"hotspot": "hotspot",
+}
+
+// sensorExpr is a parsed "sensor" setting: one sensor or max/min of
+// sub-expressions, each with an optional offset, e.g. max(core, mem-10).
+type sensorExpr struct {
+	fn     string // "max", "min", or "" for a single sensor
+	sensor string
+	args   []*sensorExpr
+	offset int
+}
+
+// parseSensorExpr parses a sensor setting; "" means core.
+func parseSensorExpr(s string) (*sensorExpr, error) {
+	if strings.TrimSpace(s) == "" {
+		return &sensorExpr{sensor: "core"}, nil
+	}
+	p := &sensorParser{in: s}
+	e, err := p.expr()
+	if err == nil && p.peek() != "" {
+		err = fmt.Errorf("unexpected %q", p.peek())
+	}
+	if err != nil {
+		return nil, fmt.Errorf("sensor %q: %w", s, err)
+	}
+	return e, nil
+}
+
+type sensorParser struct {
+	in  string
+	pos int
+}
+
+// peek returns the next token without consuming it: a word, a number or one
+// of ( ) , + -; "" at the end.
+func (p *sensorParser) peek() string {
+	for p.pos < len(p.in) && unicode.IsSpace(rune(p.in[p.pos])) {
+		p.pos++
+	}
+	if p.pos >= len(p.in) {
+		return ""
+	}
+	end := p.pos + 1
+	isWord := func(c byte) bool { return c == '_' || unicode.IsLetter(rune(c)) || unicode.IsDigit(rune(c)) }
+	if isWord(p.in[p.pos]) {
+		for end < len(p.in) && isWord(p.in[end]) {
+			end++
+		}
+	}
+	return p.in[p.pos:end]
+}
+
+func (p *sensorParser) next() string {
+	tok := p.peek()
+	p.pos += len(tok)
+	return tok
+}
+
+func (p *sensorParser) expr() (*sensorExpr, error) {
+	tok := strings.ToLower(p.next())
+	var e *sensorExpr
+	switch {
+	case tok == "max" || tok == "min":
+		e = &sensorExpr{fn: tok}
+		if p.next() != "(" {
+			return nil, fmt.Errorf("expected ( after %s", tok)
+		}
+		for {
+			arg, err := p.expr()
+			if err != nil {
+				return nil, err
+			}
+			e.args = append(e.args, arg)
+			sep := p.next()
+			if sep == ")" {
+				break
+			}
+			if sep != "," {
+				return nil, fmt.Errorf("expected , or ) in %s(...)", tok)
+			}
+		}
+	case sensorAliases[tok] != "":
+		e = &sensorExpr{sensor: sensorAliases[tok]}
+	case tok == "":
+		return nil, fmt.Errorf("unexpected end")
+	default:
+		return nil, fmt.Errorf("unknown sensor %q (expected core|mem|hotspot, max(...) or min(...))", tok)
+	}
+
+	for p.peek() == "+" || p.peek() == "-" {
+		sign := 1
+		if p.next() == "-" {
+			sign = -1
+		}
+		n, err := strconv.Atoi(p.next())
+		if err != nil {
+			return nil, fmt.Errorf("expected a number of °C after + or -")
+		}
+		e.offset += sign * n
+	}
+	return e, nil
+}
+
+func (e *sensorExpr) String() string {
+	out := e.sensor
+	if e.fn != "" {
+		args := make([]string, len(e.args))
+		for k, a := range e.args {
+			args[k] = a.String()
+		}
+		out = e.fn + "(" + strings.Join(args, ", ") + ")"
+	}
+	if e.offset != 0 {
+		out += fmt.Sprintf("%+d", e.offset)
+	}
+	return out
+}
+
+// coreOnly reports whether e is the plain core sensor the loop always reads.
+func (e *sensorExpr) coreOnly() bool {
+	return e.fn == "" && e.sensor == "core" && e.offset == 0
+}
+
+// sensors lists the distinct sensors e reads, sorted.
+func (e *sensorExpr) sensors() []string {
+	seen := map[string]bool{}
+	var walk func(*sensorExpr)
+	walk = func(x *sensorExpr) {
+		if x.fn == "" {
+			seen[x.sensor] = true
+		}
+		for _, a := range x.args {
+			walk(a)
+		}
+	}
+	walk(e)
+	out := make([]string, 0, len(seen))
+	for s := range seen {
+		out = append(out, s)
+	}
+	sort.Strings(out)
+	return out
+}
+
+// prune drops the sensors that are not ok from e. A max/min keeps its
+// remaining arguments; nil means nothing readable is left.
+func (e *sensorExpr) prune(ok map[string]bool) *sensorExpr {
+	if e.fn == "" {
+		if !ok[e.sensor] {
+			return nil
+		}
+		return e
+	}
+	var args []*sensorExpr
+	for _, a := range e.args {
+		if a = a.prune(ok); a != nil {
+			args = append(args, a)
+		}
+	}
+	switch len(args) {
+	case 0:
+		return nil
+	case 1:
+		one := *args[0]
+		one.offset += e.offset
+		return &one
+	}
+	return &sensorExpr{fn: e.fn, args: args, offset: e.offset}
+}
+
+// eval computes e from read; on failure it names the sensor that failed.
+func (e *sensorExpr) eval(read func(sensor string) (int, nvml.Return)) (int, string, nvml.Return) {
+	if e.fn == "" {
+		v, ret := read(e.sensor)
+		return v + e.offset, e.sensor, ret
+	}
+	var out int
+	for k, a := range e.args {
+		v, failed, ret := a.eval(read)
+		if ret != nvml.SUCCESS {
+			return 0, failed, ret
+		}
+		if k == 0 || (e.fn == "max" && v > out) || (e.fn == "min" && v < out) {
+			out = v
+		}
+	}
+	return out + e.offset, "", nvml.SUCCESS
+}
+
+// readSensor reads one canonical sensor of dev.
+func readSensor(dev Device, sensor string) (int, nvml.Return) {
+	switch sensor {
+	case "mem":
+		return dev.MemoryTemperature()
+	case "hotspot":
+		return dev.HotspotTemperature()
+	default:
+		return dev.Temperature()
+	}
+}
+
+// sensorUnsupported reports whether ret means the sensor does not exist on
+// this device, as opposed to a read that might succeed later.
+func sensorUnsupported(ret nvml.Return) bool {
+	return ret == nvml.ERROR_NOT_SUPPORTED || ret == nvml.ERROR_FUNCTION_NOT_FOUND || ret == nvml.ERROR_NOT_FOUND
+}
+
+// resolveSensor parses device i's sensor setting and probes every sensor it
+// reads, dropping the ones the device does not have. It returns nil when the
+// plain core temperature is the input.
+func (c *fanController) resolveSensor(i int, dev Device) *sensorExpr {
+	s := c.settings[i]
+	e, err := parseSensorExpr(s.sensor)
+	if err != nil {
+		log.Printf("WARN: %s: %v. Using the core temperature.", s.label, err)
+		return nil
+	}
+	if e.coreOnly() {
+		return nil
+	}
+	if dev == nil {
+		log.Printf("WARN: %s: no device handle to probe sensor %q; using the core temperature.", s.label, e)
+		return nil
+	}
+
+	pruned := probeSensorExpr(s.label, e, dev)
+	if pruned == nil {
+		log.Printf("WARN: %s: using the core temperature.", s.label)
+		return nil
+	}
+	if pruned.String() == e.String() {
+		log.Printf("INFO: %s: temperature input: %s.", s.label, e)
+	}
+	if pruned.coreOnly() {
+		return nil
+	}
+	return pruned
+}
+
+// probeSensorExpr reads every sensor e uses once and drops those dev does
+// not have, logging what it finds under label. nil means nothing is left.
+func probeSensorExpr(label string, e *sensorExpr, dev Device) *sensorExpr {
+	ok := map[string]bool{}
+	for _, name := range e.sensors() {
+		v, ret := readSensor(dev, name)
+		switch {
+		case ret == nvml.SUCCESS:
+			ok[name] = true
+			log.Printf("INFO: %s: %s temperature sensor available (%d°C).", label, name, v)
+		case sensorUnsupported(ret):
+			log.Printf("WARN: %s: %s temperature sensor not supported (%v).", label, name, nvml.ErrorString(ret))
+		default:
+			ok[name] = true // may be transient; runtime failures go through the read failsafe
+			log.Printf("WARN: %s: %s temperature read failed at startup (%v); keeping it.", label, name, nvml.ErrorString(ret))
+		}
+	}
+
+	pruned := e.prune(ok)
+	switch {
+	case pruned == nil:
+		log.Printf("WARN: %s: no sensor of %q is available.", label, e)
+	case pruned.String() != e.String():
+		log.Printf("WARN: %s: temperature input %q reduced to %q (unsupported sensors dropped).", label, e, pruned)
+	}
+	return pruned
+}
+
+// describeSensors lists what each temperature sensor of dev reads, for status output.
+func describeSensors(dev Device) string {
+	var parts []string
+	for _, name := range []string{"core", "mem", "hotspot"} {
+		v, ret := readSensor(dev, name)
+		if ret == nvml.SUCCESS {
+			parts = append(parts, fmt.Sprintf("%s=%d°C", name, v))
+		} else {
+			parts = append(parts, fmt.Sprintf("%s=n/a (%v)", name, nvml.ErrorString(ret)))
+		}
+	}
+	return strings.Join(parts, ", ")
+}
+
+// validateSensors checks every "sensor" setting in the config.
+func validateSensors(config Config) error {
+	if _, err := parseSensorExpr(config.Sensor); err != nil {
+		return err
+	}
+	for key, dc := range config.Devices {
+		if dc.Sensor != nil {
+			if _, err := parseSensorExpr(*dc.Sensor); err != nil {
+				return fmt.Errorf("devices[%q]: %w", key, err)
+			}
+		}
+	}
+	for key, dc := range config.Devices {
+		for fanIdx, o := range dc.Fans {
+			if o.Sensor == "" {
+				continue
+			}
+			if _, err := parseSensorExpr(o.Sensor); err != nil {
+				return fmt.Errorf("devices[%q].fans[%d]: %w", key, fanIdx, err)
+			}
+		}
+	}
+	for _, hf := range config.HwmonFans {
+		if hf.Sensor != nil {
+			if _, err := parseSensorExpr(*hf.Sensor); err != nil {
+				return fmt.Errorf("hwmon_fans[%q]: %w", hf.Name, err)
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/NVIDIA/go-nvml/pkg/nvml"
+)
+
+func TestParseSensorExpr(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    string
+		wantErr bool
+	}{
+		{in: "", want: "core"},
+		{in: "gpu", want: "core"},
+		{in: "MEM", want: "mem"},
+		{in: "memory-10", want: "mem-10"},
+		{in: "hotspot + 0", want: "hotspot"},
+		{in: "core+5-3", want: "core+2"},
+		{in: "max(core, mem-10)", want: "max(core, mem-10)"},
+		{in: "MAX( core ,mem )+2", want: "max(core, mem)+2"},
+		{in: "max(core, min(mem, hotspot+5))-2", want: "max(core, min(mem, hotspot+5))-2"},
+		{in: "max(core)", want: "max(core)"},
+		{in: "fan", wantErr: true},
+		{in: "max()", wantErr: true},
+		{in: "max(core", wantErr: true},
+		{in: "max core", wantErr: true},
+		{in: "max(core mem)", wantErr: true},
+		{in: "avg(core, mem)", wantErr: true},
+		{in: "core+", wantErr: true},
+		{in: "core+x", wantErr: true},
+		{in: "core)", wantErr: true},
+		{in: "core mem", wantErr: true},
+	}
+	for _, tt := range tests {
+		e, err := parseSensorExpr(tt.in)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%q: err=%v, want error %v", tt.in, err, tt.wantErr)
+			continue
+		}
+		if !tt.wantErr && e.String() != tt.want {
+			t.Errorf("%q parsed as %q, want %q", tt.in, e, tt.want)
+		}
+	}
+}
+
+// sensorFailDevice fails the memory and hotspot reads with the given returns.
+type sensorFailDevice struct {
+	Device
+	fail map[string]nvml.Return
+}
+
+func (d sensorFailDevice) MemoryTemperature() (int, nvml.Return) {
+	if ret, ok := d.fail["mem"]; ok {
+		return 0, ret
+	}
+	return d.Device.MemoryTemperature()
+}
+
+func (d sensorFailDevice) HotspotTemperature() (int, nvml.Return) {
+	if ret, ok := d.fail["hotspot"]; ok {
+		return 0, ret
+	}
+	return d.Device.HotspotTemperature()
+}
+
+func TestResolveSensorPrunesUnsupported(t *testing.T) {
+	unsupported := map[string]nvml.Return{"mem": nvml.ERROR_NOT_SUPPORTED}
+	tests := []struct {
+		name   string
+		sensor string
+		fail   map[string]nvml.Return
+		want   string // "" = the plain core temperature
+	}{
+		{"all supported", "max(core, mem-10)", nil, "max(core, mem-10)"},
+		{"drops to core", "max(core, mem)", unsupported, ""},
+		{"keeps the offsets", "max(core, mem-10)+2", unsupported, "core+2"},
+		{"keeps the other arguments", "max(core, mem, hotspot)", unsupported, "max(core, hotspot)"},
+		{"nested", "max(core, min(mem, hotspot))", unsupported, "max(core, hotspot)"},
+		{"a single unsupported sensor falls back to core", "mem", unsupported, ""},
+		{"nothing left", "max(mem, hotspot)", map[string]nvml.Return{"mem": nvml.ERROR_FUNCTION_NOT_FOUND, "hotspot": nvml.ERROR_NOT_FOUND}, ""},
+		{"a startup read error is kept", "max(core, mem)", map[string]nvml.Return{"mem": nvml.ERROR_UNKNOWN}, "max(core, mem)"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sim := newTestSim(t, 1, 1, []int{60})
+			c := newTestController(t, `{"sensor": "`+tt.sensor+`"}`, sim)
+			got := c.resolveSensor(0, sensorFailDevice{sim.devices[0], tt.fail})
+			switch {
+			case got == nil && tt.want != "":
+				t.Fatalf("resolved to the core temperature, want %q", tt.want)
+			case got != nil && got.String() != tt.want:
+				t.Fatalf("resolved to %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,404 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/NVIDIA/go-nvml/pkg/nvml"
+)
+
+// ---------- Simulated backend (no driver required) ----------
+
+// simAutoSpeed is what a simulated fan reports while the "driver" owns it.
+const simAutoSpeed = 30
+
+// simIdlePowerMW is what a simulated GPU draws until SetLoad is called.
+const simIdlePowerMW = 30000
+
+// Thermal limits reported by every simulated GPU.
+const (
+	simSlowdownTemp = 90
+	simShutdownTemp = 95
+)
+
+// Unless set explicitly, the simulated memory and hotspot sensors read this
+// much above the core, roughly what GDDR6X boards show under load.
+const (
+	simMemoryOffset  = 12
+	simHotspotOffset = 10
+)
+
+// Simulated fan mechanics: the board reports simMinFanSpeed as its minimum
+// (simulate and replay default to no limit, see setFanSpeedRange), the rotor stalls below simStallSpeed and stops gaining RPM above
+// simSaturatedSpeed, and RPM follows a command with a first-order lag.
+const (
+	simMinFanSpeed     = 20
+	simStallSpeed      = 28
+	simSaturatedSpeed  = 85
+	simMaxRPM          = 3000
+	simRPMTimeConstant = 300 * time.Millisecond
+)
+
+type simBackend struct {
+	devices []*simDevice
+}
+
+type simDevice struct {
+	mu       sync.Mutex
+	index    int
+	temp     int
+	memTemp  int // -1 = core + simMemoryOffset
+	hotTemp  int // -1 = core + simHotspotOffset
+	powerMW  int
+	util     int
+	speeds   []int
+	policies []nvml.FanControlPolicy
+	coolers  []coolerTarget // nil = cooler info not supported, like NVML here
+	rpmFrom  []float64      // RPM when the speed last changed
+	rpmSetAt []time.Time    // when the speed last changed
+	rpmLag   time.Duration  // RPM time constant (simRPMTimeConstant)
+	minSpeed int            // reported by MinMaxFanSpeed
+	maxSpeed int
+}
+
+func newSimBackend(gpus, fans int, temps []int) (*simBackend, error) {
+	if gpus <= 0 {
+		return nil, fmt.Errorf("simulated backend needs at least 1 GPU (got %d)", gpus)
+	}
+	if fans < 0 {
+		return nil, fmt.Errorf("simulated backend fan count must be >= 0 (got %d)", fans)
+	}
+	if len(temps) > gpus {
+		return nil, fmt.Errorf("got %d simulated temperatures for %d GPU(s)", len(temps), gpus)
+	}
+
+	b := &simBackend{devices: make([]*simDevice, gpus)}
+	for i := range b.devices {
+		d := &simDevice{
+			index:    i,
+			temp:     40,
+			memTemp:  -1,
+			hotTemp:  -1,
+			powerMW:  simIdlePowerMW,
+			speeds:   make([]int, fans),
+			policies: make([]nvml.FanControlPolicy, fans),
+			rpmFrom:  make([]float64, fans),
+			rpmSetAt: make([]time.Time, fans),
+			rpmLag:   simRPMTimeConstant,
+			minSpeed: simMinFanSpeed,
+			maxSpeed: 100,
+		}
+		if i < len(temps) {
+			d.temp = temps[i]
+		}
+		for f := range d.speeds {
+			d.speeds[f] = simAutoSpeed
+			d.policies[f] = nvml.FAN_POLICY_TEMPERATURE_CONTINOUS_SW
+			d.rpmFrom[f] = simTargetRPM(simAutoSpeed)
+		}
+		b.devices[i] = d
+	}
+	return b, nil
+}
+
+func (b *simBackend) Name() string          { return "Simulated GPU backend" }
+func (b *simBackend) Init() nvml.Return     { return nvml.SUCCESS }
+func (b *simBackend) Shutdown() nvml.Return { return nvml.SUCCESS }
+
+func (b *simBackend) DeviceCount() (int, nvml.Return) {
+	return len(b.devices), nvml.SUCCESS
+}
+
+func (b *simBackend) DeviceByIndex(idx int) (Device, nvml.Return) {
+	if idx < 0 || idx >= len(b.devices) {
+		return nil, nvml.ERROR_INVALID_ARGUMENT
+	}
+	return b.devices[idx], nvml.SUCCESS
+}
+
+func (d *simDevice) UUID() (string, nvml.Return) {
+	return fmt.Sprintf("GPU-00000000-0000-0000-0000-%012x", d.index), nvml.SUCCESS
+}
+
+func (d *simDevice) PCIBusID() (string, nvml.Return) {
+	return fmt.Sprintf("00000000:%02X:00.0", d.index+1), nvml.SUCCESS
+}
+
+func (d *simDevice) Name() (string, nvml.Return) {
+	return "Simulated GPU", nvml.SUCCESS
+}
+
+// SetTemperature lets the caller drive the simulated sensor.
+func (d *simDevice) SetTemperature(temp int) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.temp = temp
+}
+
+func (d *simDevice) Temperature() (int, nvml.Return) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.temp, nvml.SUCCESS
+}
+
+// SetMemoryTemperature and SetHotspotTemperature pin the extra sensors;
+// a negative value makes them follow the core again.
+func (d *simDevice) SetMemoryTemperature(temp int) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.memTemp = temp
+}
+
+func (d *simDevice) SetHotspotTemperature(temp int) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.hotTemp = temp
+}
+
+func (d *simDevice) MemoryTemperature() (int, nvml.Return) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.memTemp >= 0 {
+		return d.memTemp, nvml.SUCCESS
+	}
+	return d.temp + simMemoryOffset, nvml.SUCCESS
+}
+
+func (d *simDevice) HotspotTemperature() (int, nvml.Return) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.hotTemp >= 0 {
+		return d.hotTemp, nvml.SUCCESS
+	}
+	return d.temp + simHotspotOffset, nvml.SUCCESS
+}
+
+// SetPower and SetUtilization let the caller drive the simulated load.
+func (d *simDevice) SetPower(watts float64) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.powerMW = int(math.Round(watts * 1000))
+}
+
+func (d *simDevice) SetUtilization(util int) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.util = util
+}
+
+func (d *simDevice) PowerUsage() (int, nvml.Return) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.powerMW, nvml.SUCCESS
+}
+
+func (d *simDevice) Utilization() (int, int, nvml.Return) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.util, 0, nvml.SUCCESS
+}
+
+func (d *simDevice) TemperatureThreshold(kind nvml.TemperatureThresholds) (int, nvml.Return) {
+	switch kind {
+	case nvml.TEMPERATURE_THRESHOLD_SLOWDOWN:
+		return simSlowdownTemp, nvml.SUCCESS
+	case nvml.TEMPERATURE_THRESHOLD_SHUTDOWN:
+		return simShutdownTemp, nvml.SUCCESS
+	default:
+		return 0, nvml.ERROR_NOT_SUPPORTED
+	}
+}
+
+func (d *simDevice) NumFans() (int, nvml.Return) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return len(d.speeds), nvml.SUCCESS
+}
+
+func (d *simDevice) FanSpeed(fanIdx int) (int, nvml.Return) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if fanIdx < 0 || fanIdx >= len(d.speeds) {
+		return 0, nvml.ERROR_INVALID_ARGUMENT
+	}
+	return d.speeds[fanIdx], nvml.SUCCESS
+}
+
+func (d *simDevice) FanSpeedLegacy() (int, nvml.Return) {
+	return d.FanSpeed(0)
+}
+
+func (d *simDevice) MinMaxFanSpeed() (int, int, nvml.Return) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.minSpeed, d.maxSpeed, nvml.SUCCESS
+}
+
+// setFanSpeedRange sets the min/max fan speed every simulated GPU reports.
+func (b *simBackend) setFanSpeedRange(lo, hi int) error {
+	if lo < 0 || hi > 100 || lo >= hi {
+		return fmt.Errorf("need 0 <= min < max <= 100 (got %d..%d)", lo, hi)
+	}
+	for _, d := range b.devices {
+		d.mu.Lock()
+		d.minSpeed, d.maxSpeed = lo, hi
+		d.mu.Unlock()
+	}
+	return nil
+}
+
+// setCoolerTargets gives every simulated GPU the cooler targets in list
+// ("gpu,gpu,memory"), one per fan; "" leaves cooler info unsupported.
+func (b *simBackend) setCoolerTargets(list string) error {
+	if strings.TrimSpace(list) == "" {
+		return nil
+	}
+	var targets []coolerTarget
+	for _, name := range strings.Split(list, ",") {
+		t, err := parseCoolerTarget(name)
+		if err != nil {
+			return err
+		}
+		targets = append(targets, t)
+	}
+	for _, d := range b.devices {
+		if len(targets) != len(d.speeds) {
+			return fmt.Errorf("got %d cooler target(s) for %d fan(s)", len(targets), len(d.speeds))
+		}
+		d.mu.Lock()
+		d.coolers = targets
+		d.mu.Unlock()
+	}
+	return nil
+}
+
+func (d *simDevice) CoolerInfo(fanIdx int) (coolerInfo, nvml.Return) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.coolers == nil {
+		return coolerInfo{}, nvml.ERROR_NOT_SUPPORTED
+	}
+	if fanIdx < 0 || fanIdx >= len(d.coolers) {
+		return coolerInfo{}, nvml.ERROR_INVALID_ARGUMENT
+	}
+	return coolerInfo{target: d.coolers[fanIdx], control: "variable"}, nvml.SUCCESS
+}
+
+func simTargetRPM(speed int) float64 {
+	if speed < simStallSpeed {
+		return 0
+	}
+	if speed > simSaturatedSpeed {
+		speed = simSaturatedSpeed
+	}
+	return simMaxRPM * float64(speed) / simSaturatedSpeed
+}
+
+// rpmLocked is the lagged rotor speed of fanIdx at now; d.mu must be held.
+func (d *simDevice) rpmLocked(fanIdx int, now time.Time) float64 {
+	target := simTargetRPM(d.speeds[fanIdx])
+	decay := math.Exp(-float64(now.Sub(d.rpmSetAt[fanIdx])) / float64(d.rpmLag))
+	return target + (d.rpmFrom[fanIdx]-target)*decay
+}
+
+// setSpeedLocked changes the commanded speed and restarts the RPM lag; d.mu must be held.
+func (d *simDevice) setSpeedLocked(fanIdx, speed int) {
+	now := time.Now()
+	d.rpmFrom[fanIdx] = d.rpmLocked(fanIdx, now)
+	d.rpmSetAt[fanIdx] = now
+	d.speeds[fanIdx] = speed
+}
+
+func (d *simDevice) FanSpeedRPM(fanIdx int) (int, nvml.Return) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if fanIdx < 0 || fanIdx >= len(d.speeds) {
+		return 0, nvml.ERROR_INVALID_ARGUMENT
+	}
+	return int(math.Round(d.rpmLocked(fanIdx, time.Now()))), nvml.SUCCESS
+}
+
+func (d *simDevice) SetFanSpeed(fanIdx, speed int) nvml.Return {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if fanIdx < 0 || fanIdx >= len(d.speeds) || speed < 0 || speed > 100 {
+		return nvml.ERROR_INVALID_ARGUMENT
+	}
+	if d.policies[fanIdx] != nvml.FAN_POLICY_MANUAL {
+		return nvml.ERROR_NOT_SUPPORTED
+	}
+	d.setSpeedLocked(fanIdx, speed)
+	return nvml.SUCCESS
+}
+
+// SetDefaultFanSpeed hands the fan back to the simulated driver.
+func (d *simDevice) SetDefaultFanSpeed(fanIdx int) nvml.Return {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if fanIdx < 0 || fanIdx >= len(d.speeds) {
+		return nvml.ERROR_INVALID_ARGUMENT
+	}
+	d.policies[fanIdx] = nvml.FAN_POLICY_TEMPERATURE_CONTINOUS_SW
+	d.setSpeedLocked(fanIdx, simAutoSpeed)
+	return nvml.SUCCESS
+}
+
+// Policy reports the fan control policy last applied to fanIdx.
+func (d *simDevice) Policy(fanIdx int) nvml.FanControlPolicy {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if fanIdx < 0 || fanIdx >= len(d.policies) {
+		return nvml.FAN_POLICY_TEMPERATURE_CONTINOUS_SW
+	}
+	return d.policies[fanIdx]
+}
+
+func (d *simDevice) SetFanControlPolicy(fanIdx int, policy nvml.FanControlPolicy) nvml.Return {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if fanIdx < 0 || fanIdx >= len(d.speeds) {
+		return nvml.ERROR_INVALID_ARGUMENT
+	}
+	d.policies[fanIdx] = policy
+	if policy == nvml.FAN_POLICY_TEMPERATURE_CONTINOUS_SW {
+		d.setSpeedLocked(fanIdx, simAutoSpeed)
+	}
+	return nvml.SUCCESS
+}
+
+// registerBackendFlags adds -backend and the -sim-* knobs to a subcommand.
+func registerBackendFlags(fs *flag.FlagSet) *backendOptions {
+	opts := &backendOptions{}
+	fs.StringVar(&opts.name, "backend", "nvml", "GPU backend: nvml|sim")
+	fs.IntVar(&opts.simGPUs, "sim-gpus", 1, "Number of simulated GPUs (-backend sim)")
+	fs.IntVar(&opts.simFans, "sim-fans", 2, "Fans per simulated GPU (-backend sim)")
+	fs.StringVar(&opts.simTemps, "sim-temps", "", "Comma-separated temperatures per simulated GPU (-backend sim, default 40)")
+	fs.StringVar(&opts.simCoolers, "sim-coolers", "", "Comma-separated cooler target per simulated fan: gpu|memory|psu|all (-backend sim, default unsupported)")
+	return opts
+}
+
+func parseIntList(s string) ([]int, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return nil, nil
+	}
+	parts := strings.Split(s, ",")
+	out := make([]int, 0, len(parts))
+	for _, p := range parts {
+		p = strings.TrimSpace(p)
+		if p == "" {
+			continue
+		}
+		n, err := strconv.Atoi(p)
+		if err != nil {
+			return nil, fmt.Errorf("invalid integer %q: %w", p, err)
+		}
+		out = append(out, n)
+	}
+	return out, nil
+}
@@ -0,0 +1,253 @@
+package main
+
+import (
+	"encoding/csv"
+	"fmt"
+	"math"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/NVIDIA/go-nvml/pkg/nvml"
+)
+
+// ---------- simulate: thermal model driving the real control loop ----------
+
+// thermalModel is a first-order (single thermal mass) GPU model:
+//
+//	mass * dT/dt = load - (kIdle + kFan*fan/100) * (T - ambient)
+//
+// so cooling is proportional to fan speed on top of a passive baseline.
+type thermalModel struct {
+	ambient float64 // °C
+	mass    float64 // J/°C
+	kIdle   float64 // W/°C with fans stopped
+	kFan    float64 // extra W/°C at 100% fan
+	temp    float64 // °C
+}
+
+// step advances the model by dt seconds under load watts and fan percent.
+// Integrates in 1s sub-steps so long update intervals stay stable.
+func (m *thermalModel) step(dt, load float64, fan int) {
+	for dt > 0 {
+		h := math.Min(dt, 1)
+		cooling := (m.kIdle + m.kFan*float64(fan)/100) * (m.temp - m.ambient)
+		m.temp += h * (load - cooling) / m.mass
+		dt -= h
+	}
+}
+
+// timedValue is one "from this second on" entry of a schedule such as
+// "0:40,300:250" (load) or "600:on,1200:off" (gamemode).
+type timedValue struct {
+	at    int
+	value string
+}
+
+func parseSchedule(s string) ([]timedValue, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return nil, nil
+	}
+	var out []timedValue
+	for _, part := range strings.Split(s, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		at, value, ok := strings.Cut(part, ":")
+		if !ok {
+			return nil, fmt.Errorf("invalid schedule entry %q: expected SECONDS:VALUE", part)
+		}
+		sec, err := strconv.Atoi(strings.TrimSpace(at))
+		if err != nil || sec < 0 {
+			return nil, fmt.Errorf("invalid schedule time %q in %q", at, part)
+		}
+		out = append(out, timedValue{at: sec, value: strings.TrimSpace(value)})
+	}
+	sort.SliceStable(out, func(i, j int) bool { return out[i].at < out[j].at })
+	return out, nil
+}
+
+// scheduleValueAt returns the value of the last entry at or before t (or def).
+func scheduleValueAt(sched []timedValue, t int, def string) string {
+	v := def
+	for _, e := range sched {
+		if e.at > t {
+			break
+		}
+		v = e.value
+	}
+	return v
+}
+
+type simulateOptions struct {
+	configPath string
+	curve      bool
+	duration   int
+	fans       int
+	load       string
+	gamemode   string
+	ambient    float64
+	mass       float64
+	kIdle      float64
+	kFan       float64
+	startTemp  float64
+	clock      string
+	fanMin     int
+	fanMax     int
+	csv        bool
+	verbose    bool
+}
+
+func cmdSimulate(opts simulateOptions) int {
+	configureCLILogging(opts.verbose)
+
+	config, err := loadConfiguration(opts.configPath)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	if opts.curve {
+		config.Curve = true
+	}
+
+	loadSched, err := parseSchedule(opts.load)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "simulate: -load:", err)
+		return 2
+	}
+	for _, e := range loadSched {
+		if _, err := strconv.ParseFloat(e.value, 64); err != nil {
+			fmt.Fprintf(os.Stderr, "simulate: -load: invalid watts %q\n", e.value)
+			return 2
+		}
+	}
+	gmSched, err := parseSchedule(opts.gamemode)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "simulate: -gamemode:", err)
+		return 2
+	}
+	for _, e := range gmSched {
+		if e.value != "on" && e.value != "off" {
+			fmt.Fprintf(os.Stderr, "simulate: -gamemode: expected on|off, got %q\n", e.value)
+			return 2
+		}
+	}
+	start, err := parseClockFlag(opts.clock)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "simulate:", err)
+		return 2
+	}
+	if opts.mass <= 0 {
+		fmt.Fprintf(os.Stderr, "simulate: -mass must be > 0 (got %g)\n", opts.mass)
+		return 2
+	}
+
+	model := &thermalModel{
+		ambient: opts.ambient,
+		mass:    opts.mass,
+		kIdle:   opts.kIdle,
+		kFan:    opts.kFan,
+		temp:    opts.startTemp,
+	}
+
+	backend, err := newSimBackend(1, opts.fans, []int{int(math.Round(model.temp))})
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "simulate:", err)
+		return 2
+	}
+	if err := backend.setFanSpeedRange(opts.fanMin, opts.fanMax); err != nil {
+		fmt.Fprintln(os.Stderr, "simulate: invalid -fan-min/-fan-max:", err)
+		return 2
+	}
+	dev := backend.devices[0]
+
+	count, fanCounts, prevTemps, prevFanSpeeds, err := initializeDevices(backend)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+
+	gameModeLock.Store(0)
+	ctl := newFanController(backend, config, count, fanCounts, prevTemps, prevFanSpeeds)
+	t := 0
+	ctl.clock = func() time.Time { return start.Add(time.Duration(t) * time.Second) }
+
+	header := []string{"time_s", "load_w", "temp_c", "target_pct", "fan_pct", "policy", "gamemode"}
+	var (
+		cw *csv.Writer
+		tw *tabwriter.Writer
+	)
+	if opts.csv {
+		cw = csv.NewWriter(os.Stdout)
+		_ = cw.Write(header)
+	} else {
+		tw = tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', tabwriter.AlignRight)
+		fmt.Fprintln(tw, strings.Join(header, "\t")+"\t")
+	}
+
+	interval := config.TimeToUpdate
+	for ; t <= opts.duration; t += interval {
+		gm := scheduleValueAt(gmSched, t, "off")
+		if (gm == "on") != (gameModeLock.Load() == 1) {
+			setGameMode(gm == "on")
+		}
+
+		loadW, _ := strconv.ParseFloat(scheduleValueAt(loadSched, t, "0"), 64)
+		dev.SetTemperature(int(math.Round(model.temp)))
+		dev.SetPower(loadW)
+		ctl.tick()
+
+		fan := simFanAverage(dev)
+		policy := "MANUAL"
+		if dev.Policy(0) == nvml.FAN_POLICY_TEMPERATURE_CONTINOUS_SW {
+			policy = "AUTO"
+		}
+
+		row := []string{
+			strconv.Itoa(t),
+			strconv.FormatFloat(loadW, 'f', 0, 64),
+			strconv.FormatFloat(model.temp, 'f', 1, 64),
+			strconv.Itoa(ctl.targets[0]),
+			strconv.Itoa(fan),
+			policy,
+			gm,
+		}
+		if cw != nil {
+			_ = cw.Write(row)
+		} else {
+			fmt.Fprintln(tw, strings.Join(row, "\t")+"\t")
+		}
+
+		model.step(float64(interval), loadW, fan)
+	}
+
+	if cw != nil {
+		cw.Flush()
+		if err := cw.Error(); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+	} else {
+		_ = tw.Flush()
+	}
+	return 0
+}
+
+// simFanAverage is the mean reported speed across a simulated GPU's fans.
+func simFanAverage(dev *simDevice) int {
+	n, _ := dev.NumFans()
+	if n == 0 {
+		return 0
+	}
+	sum := 0
+	for f := 0; f < n; f++ {
+		s, _ := dev.FanSpeed(f)
+		sum += s
+	}
+	return int(math.Round(float64(sum) / float64(n)))
+}
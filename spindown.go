@@ -0,0 +1,110 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"math"
+)
+
+// ---------- Spin-down hold and decay ----------
+
+// SpinDownConfig delays a falling target so bursty loads (compiles, short
+// benchmarks) don't spin the fans up and down on every burst.
+type SpinDownConfig struct {
+	HoldSeconds    int     `json:"hold_seconds,omitempty"`     // keep the peak speed this long after the target starts falling
+	DecayPerSecond float64 `json:"decay_per_second,omitempty"` // then lower it by this many %/s (0 = drop straight to the target)
+}
+
+func (s SpinDownConfig) validate() error {
+	if s.HoldSeconds < 0 || s.DecayPerSecond < 0 {
+		return fmt.Errorf("hold_seconds and decay_per_second must be >= 0")
+	}
+	if s.HoldSeconds == 0 && s.DecayPerSecond == 0 {
+		return fmt.Errorf("set hold_seconds and/or decay_per_second")
+	}
+	return nil
+}
+
+func (s SpinDownConfig) String() string {
+	decay := "then drop to the target"
+	if s.DecayPerSecond > 0 {
+		decay = fmt.Sprintf("then decay at %g%%/s", s.DecayPerSecond)
+	}
+	return fmt.Sprintf("hold the peak for %ds after the target falls, %s", s.HoldSeconds, decay)
+}
+
+const (
+	spinFollow = iota // running the target
+	spinHold          // target fell; holding the peak
+	spinDecay         // hold over; moving down toward the target
+)
+
+// spinDownState is the per-device hold/decay progress, on the controller clock.
+type spinDownState struct {
+	phase     int
+	level     float64 // speed being run; -1 = no target seen yet
+	goal      int     // undelayed target
+	holdUntil float64
+}
+
+func newSpinDownState() spinDownState {
+	return spinDownState{level: -1}
+}
+
+// holdSpinDown returns the speed device i runs instead of target: the target
+// itself while it is steady or rising, otherwise the held or decaying peak.
+func (c *fanController) holdSpinDown(i, target int) int {
+	cfg := c.settings[i].spinDown
+	if cfg == nil {
+		return target
+	}
+	st := &c.spinDown[i]
+	label := c.settings[i].label
+	st.goal = target
+	if st.level < 0 || float64(target) >= st.level {
+		st.phase, st.level = spinFollow, float64(target)
+		return target
+	}
+
+	if st.phase == spinFollow {
+		st.phase, st.holdUntil = spinHold, c.now+float64(cfg.HoldSeconds)
+		if cfg.HoldSeconds > 0 {
+			log.Printf("INFO: %s spin-down: target fell to %d%%, holding %d%% for %ds.",
+				label, target, int(math.Round(st.level)), cfg.HoldSeconds)
+		}
+	}
+	if st.phase == spinHold {
+		if c.now < st.holdUntil {
+			return int(math.Round(st.level))
+		}
+		st.phase = spinDecay
+		if cfg.DecayPerSecond > 0 {
+			log.Printf("INFO: %s spin-down: decaying from %d%% toward %d%% at %g%%/s.",
+				label, int(math.Round(st.level)), target, cfg.DecayPerSecond)
+		}
+	}
+
+	st.level = math.Max(float64(target), st.level-cfg.DecayPerSecond*c.dt)
+	if cfg.DecayPerSecond <= 0 || st.level <= float64(target) {
+		st.phase, st.level = spinFollow, float64(target)
+	}
+	return int(math.Round(st.level))
+}
+
+// spinningDown reports whether device i is holding or decaying, so the curve
+// keeps updating even while the temperature stays inside its hysteresis.
+func (c *fanController) spinningDown(i int) bool {
+	return c.settings[i].spinDown != nil && c.spinDown[i].phase != spinFollow
+}
+
+// spinDownDetail is the spin-down part of the update log line, or "".
+func (c *fanController) spinDownDetail(i int) string {
+	if !c.spinningDown(i) {
+		return ""
+	}
+	phase := "hold"
+	if c.spinDown[i].phase == spinDecay {
+		phase = "decay"
+	}
+	return fmt.Sprintf(", Goal=%d%% (spin-down %s)", c.spinDown[i].goal, phase)
+}
@@ -0,0 +1,117 @@
+package main
+
+import "testing"
+
+// TestSpinDownTiming drives a step-mode GPU on the controller clock: 80% at
+// 60°C, 30% at 40°C. Each step gives the update time, the temperature and
+// the speed the fan must run.
+func TestSpinDownTiming(t *testing.T) {
+	type step struct {
+		at   float64
+		temp int
+		want int
+	}
+	tests := []struct {
+		name     string
+		spinDown string
+		steps    []step
+	}{
+		{
+			name:     "hold then decay",
+			spinDown: `{"hold_seconds": 10, "decay_per_second": 5}`,
+			steps: []step{
+				{1, 60, 80},
+				{2, 40, 80}, // target fell: hold until 12s
+				{6, 40, 80},
+				{11, 40, 80},
+				{12, 40, 75}, // hold over: decay 5%/s
+				{14, 40, 65},
+				{20, 40, 35},
+				{21, 40, 30}, // reached the target
+				{22, 40, 30},
+			},
+		},
+		{
+			name:     "hold only drops straight to the target",
+			spinDown: `{"hold_seconds": 5}`,
+			steps: []step{
+				{1, 60, 80},
+				{2, 40, 80},
+				{6, 40, 80},
+				{7, 40, 30},
+			},
+		},
+		{
+			name:     "decay only",
+			spinDown: `{"decay_per_second": 10}`,
+			steps: []step{
+				{1, 60, 80},
+				{2, 40, 70},
+				{4, 40, 50},
+				{10, 40, 30},
+			},
+		},
+		{
+			name:     "a rising target releases the hold",
+			spinDown: `{"hold_seconds": 10, "decay_per_second": 5}`,
+			steps: []step{
+				{1, 60, 80},
+				{2, 40, 80},
+				{5, 60, 80}, // back at the peak: follow again
+				{6, 40, 80}, // a new fall starts a new hold, until 16s
+				{15, 40, 80},
+				{16, 40, 75},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sim := newTestSim(t, 1, 1, []int{60})
+			c := newTestController(t, `{
+				"time_to_update": 1,
+				"spin_down": `+tt.spinDown+`,
+				"temperature_ranges": [
+					{"min_temperature": 0, "max_temperature": 50, "fan_speed": 30, "hysteresis": 2},
+					{"min_temperature": 50, "max_temperature": 200, "fan_speed": 80, "hysteresis": 2}
+				]
+			}`, sim)
+			for _, s := range tt.steps {
+				sim.devices[0].SetTemperature(s.temp)
+				c.tickAt(s.at)
+				if got, _ := sim.devices[0].FanSpeed(0); got != s.want {
+					t.Fatalf("at %gs (%d°C): fan at %d%%, want %d%%", s.at, s.temp, got, s.want)
+				}
+			}
+		})
+	}
+}
+
+// TestSpinDownDroppedBelowFloor checks that a hold does not survive the
+// curve falling back to AUTO below its floor.
+func TestSpinDownDroppedBelowFloor(t *testing.T) {
+	sim := newTestSim(t, 1, 1, []int{70})
+	c := newTestController(t, `{
+		"time_to_update": 1,
+		"spin_down": {"hold_seconds": 30},
+		"curve_points": {
+			"floor": {"temperature": 45},
+			"points": [{"temp": 50, "speed": 40}, {"temp": 70, "speed": 80}]
+		}
+	}`, sim)
+	c.tickAt(1)
+	sim.devices[0].SetTemperature(55)
+	c.tickAt(2)
+	if !c.spinningDown(0) {
+		t.Fatal("not holding after the target fell")
+	}
+	sim.devices[0].SetTemperature(40)
+	c.tickAt(3)
+	if !c.inAuto[0] || c.spinningDown(0) {
+		t.Fatalf("below the floor: in AUTO %v, spinning down %v; want AUTO without a hold", c.inAuto[0], c.spinningDown(0))
+	}
+	sim.devices[0].SetTemperature(55)
+	c.tickAt(4)
+	if got, _ := sim.devices[0].FanSpeed(0); got != 50 {
+		t.Fatalf("fan at %d%% after leaving AUTO, want the curve's 50%% rather than the old peak", got)
+	}
+}
@@ -0,0 +1,251 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"math"
+	"strings"
+
+	"github.com/NVIDIA/go-nvml/pkg/nvml"
+)
+
+// ---------- Thermal zones (several GPUs driving their fans together) ----------
+
+// ZoneConfig groups GPUs, and optionally hwmon fans, whose fans all follow
+// one temperature aggregated from the member GPUs.
+type ZoneConfig struct {
+	Name      string        `json:"name"`
+	GPUs      []GPUSelector `json:"gpus"`                 // members; a selector may match several GPUs
+	HwmonFans []string      `json:"hwmon_fans,omitempty"` // hwmon_fans entries (by name) that follow the zone
+	Aggregate string        `json:"aggregate,omitempty"`  // "max" (default), "mean" or "weighted"
+	Weights   []float64     `json:"weights,omitempty"`    // "weighted": one weight per gpus entry
+}
+
+// validateZones checks the zones section against the rest of the config.
+func validateZones(config Config) error {
+	seen := map[string]bool{}
+	hwmon := map[string]bool{}
+	for _, hf := range config.HwmonFans {
+		hwmon[hf.Name] = true
+	}
+	for n, z := range config.Zones {
+		if z.Name == "" {
+			return fmt.Errorf("zones[%d]: name must be set", n)
+		}
+		if seen[z.Name] {
+			return fmt.Errorf("zones[%d]: duplicate zone name %q", n, z.Name)
+		}
+		seen[z.Name] = true
+		if len(z.GPUs) == 0 {
+			return fmt.Errorf("zone %q: gpus must not be empty", z.Name)
+		}
+		for _, sel := range z.GPUs {
+			if _, err := sel.matches(-1, deviceIdentity{}); err != nil {
+				return fmt.Errorf("zone %q: %w", z.Name, err)
+			}
+		}
+		switch z.Aggregate {
+		case "", "max", "mean":
+			if len(z.Weights) > 0 {
+				return fmt.Errorf("zone %q: weights need aggregate \"weighted\"", z.Name)
+			}
+		case "weighted":
+			if len(z.Weights) != len(z.GPUs) {
+				return fmt.Errorf("zone %q: need one weight per gpus entry (got %d for %d)", z.Name, len(z.Weights), len(z.GPUs))
+			}
+			for k, w := range z.Weights {
+				if w <= 0 {
+					return fmt.Errorf("zone %q: weight %d must be > 0 (got %g)", z.Name, k, w)
+				}
+			}
+		default:
+			return fmt.Errorf("zone %q: unknown aggregate %q (expected max|mean|weighted)", z.Name, z.Aggregate)
+		}
+		for _, name := range z.HwmonFans {
+			if !hwmon[name] {
+				return fmt.Errorf("zone %q: no hwmon_fans entry named %q", z.Name, name)
+			}
+		}
+	}
+	return nil
+}
+
+// thermalZone is a resolved zone and its latest aggregate.
+type thermalZone struct {
+	name      string
+	aggregate string
+	members   []int     // GPU device indices whose temperatures are aggregated
+	weights   []float64 // per member
+	followers []int     // every device whose fans follow the zone (members + hwmon fans)
+	temp      int
+	driver    int  // member currently driving the zone, -1 = none yet
+	ok        bool // at least one member was read this update
+}
+
+// resolveZones maps the configured zones onto device indices. Problems are
+// logged and skip the member or zone, like an unmatched devices section.
+func (c *fanController) resolveZones(zones []ZoneConfig) (out []*thermalZone, zoneOf []int) {
+	zoneOf = make([]int, c.count)
+	for i := range zoneOf {
+		zoneOf[i] = -1
+	}
+	devs := make([]Device, c.count)
+	for i := 0; i < c.count; i++ {
+		if dev, ret := c.backend.DeviceByIndex(i); ret == nvml.SUCCESS {
+			devs[i] = dev
+		}
+	}
+
+	for _, zc := range zones {
+		z := &thermalZone{name: zc.Name, aggregate: zc.Aggregate, driver: -1}
+		if z.aggregate == "" {
+			z.aggregate = "max"
+		}
+		join := func(i int) bool {
+			if zoneOf[i] >= 0 {
+				log.Printf("WARN: Zone %q: %s already belongs to zone %q; skipping it.", zc.Name, c.settings[i].label, out[zoneOf[i]].name)
+				return false
+			}
+			zoneOf[i] = len(out)
+			z.followers = append(z.followers, i)
+			return true
+		}
+
+		for k, sel := range zc.GPUs {
+			matched := false
+			var err error
+			for i, dev := range devs {
+				if dev == nil {
+					continue
+				}
+				if _, ok := dev.(*hwmonFan); ok {
+					continue
+				}
+				var ok bool
+				if ok, err = sel.matches(i, identifyDevice(dev)); err != nil {
+					log.Printf("WARN: Zone %q: %v", zc.Name, err)
+					break
+				}
+				if !ok {
+					continue
+				}
+				matched = true
+				if join(i) {
+					w := 1.0
+					if z.aggregate == "weighted" {
+						w = zc.Weights[k]
+					}
+					z.members = append(z.members, i)
+					z.weights = append(z.weights, w)
+				}
+			}
+			if !matched && err == nil {
+				log.Printf("WARN: Zone %q: no GPU matches %q.", zc.Name, sel)
+			}
+		}
+		for _, name := range zc.HwmonFans {
+			for i, dev := range devs {
+				if f, ok := dev.(*hwmonFan); ok && f.cfg.Name == name {
+					join(i)
+				}
+			}
+		}
+
+		if len(z.members) == 0 {
+			log.Printf("WARN: Zone %q has no GPUs; its devices keep their own temperatures.", zc.Name)
+			for _, i := range z.followers {
+				zoneOf[i] = -1
+			}
+			continue
+		}
+		out = append(out, z)
+		weights := z.weights
+		if z.aggregate != "weighted" {
+			weights = nil
+		}
+		log.Printf("INFO: Zone %q: %s of %s drives %s.", z.name, z.aggregate, c.deviceList(z.members, weights), c.deviceList(z.followers, nil))
+	}
+	return out, zoneOf
+}
+
+// deviceList names devices for logs, with weights when given.
+func (c *fanController) deviceList(idx []int, weights []float64) string {
+	parts := make([]string, len(idx))
+	for k, i := range idx {
+		parts[k] = c.settings[i].label
+		if weights != nil {
+			parts[k] += fmt.Sprintf("×%g", weights[k])
+		}
+	}
+	return "[" + strings.Join(parts, ", ") + "]"
+}
+
+// updateZones aggregates the temperatures read this update (ok[i] false = not
+// read) and logs when a different member starts driving a zone.
+func (c *fanController) updateZones(temps []int, ok []bool) {
+	for _, z := range c.zones {
+		sum, wsum, top := 0.0, 0.0, math.Inf(-1)
+		hottest, driver := 0, -1
+		for k, i := range z.members {
+			if !ok[i] {
+				continue
+			}
+			w := z.weights[k]
+			sum += w * float64(temps[i])
+			wsum += w
+			if driver < 0 || temps[i] > hottest {
+				hottest = temps[i]
+			}
+			// The driver is the member contributing most (the hottest, for max and mean).
+			if share := w * float64(temps[i]); share > top {
+				top, driver = share, i
+			}
+		}
+		z.ok = driver >= 0
+		if !z.ok {
+			continue
+		}
+		if z.aggregate == "max" {
+			z.temp = hottest
+		} else {
+			z.temp = int(math.Round(sum / wsum))
+		}
+		if driver != z.driver {
+			log.Printf("INFO: Zone %q is now driven by %s (%d°C): zone temp=%d°C (%s).",
+				z.name, c.settings[driver].label, temps[driver], z.temp, z.aggregate)
+			z.driver = driver
+		}
+	}
+}
+
+// controlTemp is the temperature device i's fans follow: its zone's aggregate
+// when it is in a zone that could be read, else its own.
+func (c *fanController) controlTemp(i, own int) int {
+	if z := c.zoneFor(i); z != nil {
+		return z.temp
+	}
+	return own
+}
+
+// zoneFor returns the zone device i follows this update, or nil.
+func (c *fanController) zoneFor(i int) *thermalZone {
+	if c.zoneOf == nil || c.zoneOf[i] < 0 {
+		return nil
+	}
+	if z := c.zones[c.zoneOf[i]]; z.ok {
+		return z
+	}
+	return nil
+}
+
+// zoneStatus summarizes every zone for STATUS=, or "".
+func (c *fanController) zoneStatus() string {
+	var parts []string
+	for _, z := range c.zones {
+		if z.driver < 0 {
+			continue
+		}
+		parts = append(parts, fmt.Sprintf("zone %s %d°C via %s", z.name, z.temp, c.settings[z.driver].label))
+	}
+	return strings.Join(parts, "; ")
+}
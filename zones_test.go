@@ -0,0 +1,114 @@
+package main
+
+import "testing"
+
+func TestUpdateZones(t *testing.T) {
+	tests := []struct {
+		name       string
+		zone       string
+		temps      []int
+		ok         []bool
+		wantOK     bool
+		wantTemp   int
+		wantDriver int
+	}{
+		{
+			name:  "max",
+			zone:  `{"name": "z", "gpus": [0, 1, 2]}`,
+			temps: []int{60, 70, 50}, ok: []bool{true, true, true},
+			wantOK: true, wantTemp: 70, wantDriver: 1,
+		},
+		{
+			name:  "mean",
+			zone:  `{"name": "z", "gpus": [0, 1, 2], "aggregate": "mean"}`,
+			temps: []int{60, 70, 50}, ok: []bool{true, true, true},
+			wantOK: true, wantTemp: 60, wantDriver: 1,
+		},
+		{
+			name:  "weighted",
+			zone:  `{"name": "z", "gpus": [0, 1, 2], "aggregate": "weighted", "weights": [1, 1, 2]}`,
+			temps: []int{60, 70, 50}, ok: []bool{true, true, true},
+			wantOK: true, wantTemp: 58, wantDriver: 2, // (60+70+2×50)/4; 2×50 is the largest share
+		},
+		{
+			name:  "a weight applies to every GPU its selector matches",
+			zone:  `{"name": "z", "gpus": ["name:simulated"], "aggregate": "weighted", "weights": [3]}`,
+			temps: []int{60, 70, 50}, ok: []bool{true, true, true},
+			wantOK: true, wantTemp: 60, wantDriver: 1,
+		},
+		{
+			name:  "unread members are left out",
+			zone:  `{"name": "z", "gpus": [0, 1, 2], "aggregate": "mean"}`,
+			temps: []int{60, 90, 50}, ok: []bool{true, false, true},
+			wantOK: true, wantTemp: 55, wantDriver: 0,
+		},
+		{
+			name:  "no member read",
+			zone:  `{"name": "z", "gpus": [0, 1]}`,
+			temps: []int{60, 70, 50}, ok: []bool{false, false, true},
+			wantOK: false, wantDriver: -1,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sim := newTestSim(t, 3, 1, nil)
+			c := newTestController(t, `{"zones": [`+tt.zone+`]}`, sim)
+			if len(c.zones) != 1 {
+				t.Fatalf("%d zone(s) resolved, want 1", len(c.zones))
+			}
+			c.updateZones(tt.temps, tt.ok)
+			z := c.zones[0]
+			if z.ok != tt.wantOK || z.driver != tt.wantDriver {
+				t.Fatalf("ok %v, driver %d; want %v, %d", z.ok, z.driver, tt.wantOK, tt.wantDriver)
+			}
+			if tt.wantOK && z.temp != tt.wantTemp {
+				t.Errorf("zone temp %d°C, want %d°C", z.temp, tt.wantTemp)
+			}
+		})
+	}
+}
+
+// TestZoneMembersShareTarget runs the control loop with GPUs 0 and 1 in a
+// zone and GPU 2 outside it: the zone members run the speed of the hottest
+// member, GPU 2 its own.
+func TestZoneMembersShareTarget(t *testing.T) {
+	sim := newTestSim(t, 3, 2, []int{40, 70, 40})
+	c := newTestController(t, `{
+		"time_to_update": 5,
+		"zones": [{"name": "front", "gpus": [0, 1]}],
+		"temperature_ranges": [
+			{"min_temperature": 0, "max_temperature": 50, "fan_speed": 30, "hysteresis": 2},
+			{"min_temperature": 50, "max_temperature": 200, "fan_speed": 80, "hysteresis": 2}
+		]
+	}`, sim)
+	check := func(step string, want []int) {
+		t.Helper()
+		for i, w := range want {
+			for fanIdx := 0; fanIdx < 2; fanIdx++ {
+				if got, _ := sim.devices[i].FanSpeed(fanIdx); got != w {
+					t.Fatalf("%s: GPU %d fan %d at %d%%, want %d%%", step, i, fanIdx, got, w)
+				}
+			}
+		}
+	}
+	c.tick()
+	check("GPU 1 hot", []int{80, 80, 30})
+	if z := c.zoneFor(0); z == nil || z.driver != 1 || z.temp != 70 {
+		t.Fatalf("GPU 0 follows %+v, want zone front at 70°C driven by GPU 1", z)
+	}
+	if c.zoneFor(2) != nil {
+		t.Fatal("GPU 2 follows a zone it is not in")
+	}
+
+	sim.devices[1].SetTemperature(40)
+	sim.devices[0].SetTemperature(65)
+	c.tick()
+	check("GPU 0 hot", []int{80, 80, 30})
+	if z := c.zoneFor(1); z.driver != 0 {
+		t.Fatalf("zone driven by device %d, want GPU 0", z.driver)
+	}
+
+	sim.devices[0].SetTemperature(40)
+	c.tick()
+	check("both cool", []int{30, 30, 30})
+}